@@ -2,105 +2,704 @@ package tnetstrings
 
 import (
 	"bufio"
-	"bytes"
+	"encoding"
 	"io"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"unsafe"
 )
 
 const limit = 10
 
+// Unmarshaler is the interface implemented by types that can unmarshal a tnetstring of themselves.
+// The input is a single complete tnetstring including its size and type char as it appears in the input.
+// UnmarshalTNetstring must copy the data if it wishes to retain the data after returning.
+type Unmarshaler interface {
+	UnmarshalTNetstring([]byte) error
+}
+
+var (
+	unmarshalerType       = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+	interfaceMapType      = reflect.TypeOf(map[string]interface{}{})
+	interfaceSliceType    = reflect.TypeOf([]interface{}{})
+)
+
+// StringTarget is what a string is decoded into for an interface{} destination.
+type StringTarget int
+
+const (
+	// TargetString decodes a string into a string.
+	TargetString StringTarget = iota
+
+	// TargetBytes decodes a string into a []byte.
+	TargetBytes
+)
+
+// DecoderOptions configures decoding. The zero value is the default behavior.
+type DecoderOptions struct {
+	// DisallowUnknownFields makes decoding into a struct fail with an ErrUnknownField
+	// when the input contains a key which doesn't match any field.
+	DisallowUnknownFields bool
+
+	// ByteStrings is what an interface{} receives for a `,` string.
+	ByteStrings StringTarget
+
+	// TextStrings is what an interface{} receives for a `;` string.
+	TextStrings StringTarget
+
+	// UseNumber makes integers and floats decoded into an interface{} a Number instead of an int64 and a float64.
+	UseNumber bool
+
+	// NonFinite is the policy for NaN and ±Inf floats.
+	NonFinite NonFinitePolicy
+
+	// TimeLayout is how integers and floats are decoded into time.Time and time.Duration unless a struct tag
+	// says otherwise. Strings are always accepted.
+	TimeLayout TimeLayout
+
+	// Strict makes decoding fail with a *SyntaxError on input which is well-formed but doesn't follow the spec
+	// exactly: SIZE with leading zeros, an integer other than decimal, a boolean other than `true` and `false`,
+	// a null with a payload and a `;` string which isn't valid UTF-8. Dictionary keys must be strings regardless.
+	Strict bool
+
+	// Borrow makes decoded strings and byte slices alias the input instead of copying it.
+	// With Unmarshal the input must not be modified while the results are in use.
+	// With a Decoder each value is read into its own buffer.
+	Borrow bool
+
+	// The limits below are for untrusted input. Zero means no limit.
+
+	// MaxValueSize limits SIZE of a tnetstring. It's checked before the payload is read.
+	MaxValueSize uint64
+
+	// MaxDepth limits the nesting of dictionaries and lists.
+	MaxDepth int
+
+	// MaxTotalBytes limits the bytes a Decoder reads in total, or the length of the input of Unmarshal.
+	MaxTotalBytes int64
+
+	// MaxContainerItems limits the entries of a dictionary and the items of a list.
+	MaxContainerItems int
+}
+
+// NewDecoder returns a new Decoder instance which decodes with the options.
+func (o DecoderOptions) NewDecoder(r io.Reader) *Decoder {
+	d := NewDecoder(r)
+	d.state.opts = o
+	return d
+}
+
+// Unmarshal decodes exactly one tnetstring from data into val with the options.
+func (o DecoderOptions) Unmarshal(data []byte, val interface{}) error {
+	if o.MaxTotalBytes > 0 && int64(len(data)) > o.MaxTotalBytes {
+		return &LimitError{Offset: o.MaxTotalBytes, Err: ErrMaxTotalBytes}
+	}
+	rv, err := target(val)
+	if err != nil {
+		return err
+	}
+	payload, t, rest, pos, err := scan(data)
+	if err != nil {
+		return &SyntaxError{Offset: int64(pos), Err: err}
+	}
+	if o.MaxValueSize > 0 && uint64(len(payload)) > o.MaxValueSize {
+		return &LimitError{Offset: int64(len(data) - len(rest) - len(payload) - 1), Err: ErrMaxValueSize}
+	}
+	if len(rest) != 0 {
+		return &SyntaxError{Offset: int64(len(data) - len(rest)), Err: ErrTrailingData}
+	}
+	off := int64(len(data) - len(rest) - len(payload) - 1)
+	s := decodeState{opts: o}
+	if o.Strict {
+		if err := s.checkStrict(0, t, payload, off); err != nil {
+			return err
+		}
+	}
+	return s.value(typeDecoder(rv.Type()), data, t, payload, off, rv)
+}
+
 // Decoder is a streaming tnetstrings decoder.
+//
+// Token may read a payload larger than the buffer ahead and push it back, which resets the embedded Reader.
+// If the Decoder wasn't created by NewDecoder or reset by Reset, the first push back also replaces Reader
+// with a new one reading from the old one. Read through the Decoder rather than holding on to Reader.
 type Decoder struct {
 	*bufio.Reader
+	offset int64
+	buf    []byte
+	state  decodeState
+	stack  []frame
+
+	// src is what Reader reads from: the underlying reader after the bytes pushed back by Token.
+	src *pendingReader
+
+	// at is the underlying reader if it supports random access and base is its offset when the Decoder was created.
+	at   io.ReaderAt
+	base int64
 }
 
 // NewDecoder returns a new Decoder instance.
 func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{Reader: bufio.NewReader(r)}
+	var d Decoder
+	d.Reset(r)
+	return &d
+}
+
+// Reset discards the buffered data and the dictionaries and lists opened by Token, and makes the Decoder
+// read from r as if it were new. The options are kept.
+func (d *Decoder) Reset(r io.Reader) {
+	if d.src == nil {
+		d.src = &pendingReader{}
+	}
+	d.src.pending, d.src.r = nil, r
+	if d.Reader == nil {
+		d.Reader = bufio.NewReader(d.src)
+	} else {
+		d.Reader.Reset(d.src)
+	}
+	d.offset = 0
+	d.stack = d.stack[:0]
+	d.at, d.base = nil, 0
+	if at, ok := r.(io.ReaderAt); ok {
+		d.at = at
+		if s, ok := r.(io.Seeker); ok {
+			base, err := s.Seek(0, io.SeekCurrent)
+			if err != nil {
+				d.at = nil
+			}
+			d.base = base
+		}
+	}
+}
+
+// DisallowUnknownFields causes the Decoder to return an ErrUnknownField when the destination is a struct
+// and the input contains a key which doesn't match any field.
+func (d *Decoder) DisallowUnknownFields() {
+	d.state.opts.DisallowUnknownFields = true
+}
+
+// UseNumber causes the Decoder to decode integers and floats into an interface{} as a Number.
+func (d *Decoder) UseNumber() {
+	d.state.opts.UseNumber = true
 }
 
 // Decode decodes a tnetstring from the stream.
+// Errors in the input are reported as a *SyntaxError or a *DecodeError. At the end of the stream it returns io.EOF.
 func (d *Decoder) Decode(val interface{}) error {
-	size, err := d.size()
+	rv, err := target(val)
+	if err != nil {
+		return err
+	}
+	if err := d.countItem(); err != nil {
+		return err
+	}
+	start := d.offset
+	size, err := d.readSize()
 	if err != nil {
 		return err
 	}
-	data := make([]uint8, size+1)
-	if _, err = io.ReadFull(d, data[:]); err != nil {
+	if err := d.checkSize(d.offset, size); err != nil {
+		return err
+	}
+	off := d.offset
+	n := uint64(off - start)
+	raw := d.buf
+	if d.state.opts.Borrow || uint64(cap(raw)) < n+size+1 {
+		raw = make([]byte, n+size+1)
+	}
+	raw = appendSize(raw[:0], size, int(n))[:n+size+1]
+	if !d.state.opts.Borrow {
+		d.buf = raw
+	}
+	if err := d.readFull(raw[n:]); err != nil {
+		return err
+	}
+	t, data := raw[n+size], raw[n:n+size]
+	if !isTypeChar(t) {
+		return &SyntaxError{Offset: d.offset - 1, Err: ErrInvalidTypeChar(t)}
+	}
+	if err := d.checkKey(t, d.offset-1); err != nil {
 		return err
 	}
+	d.state.depth = len(d.stack)
+	if d.state.opts.Strict {
+		if err := d.state.checkStrict(start, t, data, off); err != nil {
+			return err
+		}
+	}
+	return d.state.value(typeDecoder(rv.Type()), raw, t, data, off, rv)
+}
+
+// appendSize appends SIZE and `:` to b as the n bytes they were read as, including leading zeros.
+func appendSize(b []byte, size uint64, n int) []byte {
+	digits := 1
+	for s := size; s >= 10; s /= 10 {
+		digits++
+	}
+	for i := digits + 1; i < n; i++ {
+		b = append(b, '0')
+	}
+	b = strconv.AppendUint(b, size, 10)
+	return append(b, ':')
+}
+
+// target returns what val points to, or an ErrInvalidUnmarshal if val isn't a non-nil pointer.
+func target(val interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return reflect.Value{}, ErrInvalidUnmarshal{Type: reflect.TypeOf(val)}
+	}
+	return rv.Elem(), nil
+}
+
+// checkSize checks the limits before the payload of size bytes at offset off and its type char are read.
+func (d *Decoder) checkSize(off int64, size uint64) error {
+	o := &d.state.opts
+	if o.MaxValueSize > 0 && size > o.MaxValueSize {
+		return &LimitError{Offset: off, Err: ErrMaxValueSize}
+	}
+	if o.MaxTotalBytes > 0 && (off >= o.MaxTotalBytes || size >= uint64(o.MaxTotalBytes-off)) {
+		return &LimitError{Offset: off, Err: ErrMaxTotalBytes}
+	}
+	return nil
+}
+
+// readSize reads SIZE and the following `:` from the stream.
+// It returns io.EOF as is only if the stream ends before the first byte.
+func (d *Decoder) readSize() (uint64, error) {
+	size, n, err := readSize(d)
+	d.offset += int64(n)
+	switch err {
+	case nil:
+		return size, nil
+	case io.EOF:
+		if n == 0 {
+			return 0, err
+		}
+		return 0, &SyntaxError{Offset: d.offset, Err: io.ErrUnexpectedEOF}
+	case ErrSizeLimitExceeded:
+		return 0, &SyntaxError{Offset: d.offset - 1, Err: err}
+	}
+	if _, ok := err.(ErrInvalidSizeChar); ok {
+		return 0, &SyntaxError{Offset: d.offset - 1, Err: err}
+	}
+	return 0, err
+}
 
-	t := data[len(data)-1]
-	data = data[:len(data)-1]
-	rv := reflect.Indirect(reflect.ValueOf(val))
+// readFull fills p from the stream.
+func (d *Decoder) readFull(p []byte) error {
+	n, err := io.ReadFull(d, p)
+	d.offset += int64(n)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &SyntaxError{Offset: d.offset, Err: io.ErrUnexpectedEOF}
+	}
+	return err
+}
+
+func isTypeChar(t byte) bool {
 	switch t {
-	case ',', ';':
-		return decodeString(data, rv)
-	case '#':
-		return decodeInteger(data, rv)
-	case '^':
-		return decodeFloat(data, rv)
-	case '!':
-		return decodeBool(data, rv)
-	case '~':
-		return decodeNull(data, rv)
-	case '}':
-		return decodeDictionary(data, rv)
-	case ']':
-		return decodeList(data, rv)
+	case ',', ';', '#', '^', '!', '~', '}', ']':
+		return true
+	default:
+		return false
 	}
-	return ErrInvalidTypeChar(t)
 }
 
 // More returns true iff the underlying stream can return more than 1 byte.
+// Inside a dictionary or a list opened by Token it reports whether there's another element.
 func (d *Decoder) More() bool {
+	if n := len(d.stack); n > 0 {
+		return d.offset < d.stack[n-1].end
+	}
 	_, err := d.Reader.Peek(1)
 	return err == nil
 }
 
-func (d *Decoder) size() (uint64, error) {
+// readSize reads SIZE and the following `:` and returns SIZE along with the number of bytes read.
+func readSize(r io.ByteReader) (uint64, int, error) {
 	var size uint64
 	for i := 0; i < limit; i++ {
-		b, err := d.ReadByte()
+		b, err := r.ReadByte()
 		if err != nil {
-			return 0, err
+			return 0, i, err
 		}
 		switch b {
 		case ':':
-			return size, nil
+			return size, i + 1, nil
 		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 			size = 10*size + uint64(b-'0')
 		default:
-			return 0, ErrInvalidSizeChar(b)
+			return 0, i + 1, ErrInvalidSizeChar(b)
 		}
 	}
-	return 0, ErrSizeLimitExceeded
+	return 0, limit, ErrSizeLimitExceeded
+}
+
+// decodeState holds what's shared while decoding a single top-level value.
+type decodeState struct {
+	opts  DecoderOptions
+	depth int
+
+	// raw is the whole tnetstring passed to value, which is given to an Unmarshaler as is.
+	raw []byte
 }
 
-func decodeString(data []byte, rv reflect.Value) error {
+// enter checks the depth limit for a dictionary or a list whose payload is found at offset off.
+// It must be paired with leave.
+func (d *decodeState) enter(off int64) error {
+	d.depth++
+	if d.opts.MaxDepth > 0 && d.depth > d.opts.MaxDepth {
+		return &LimitError{Offset: off, Err: ErrMaxDepth}
+	}
+	return nil
+}
+
+func (d *decodeState) leave() {
+	d.depth--
+}
+
+// checkItems checks the item limit before the i-th element of a dictionary or a list found at offset off.
+func (d *decodeState) checkItems(i int, off int64) error {
+	if d.opts.MaxContainerItems > 0 && i >= d.opts.MaxContainerItems {
+		return &LimitError{Offset: off, Err: ErrMaxContainerItems}
+	}
+	return nil
+}
+
+// string returns data as a string aliasing it if borrowing is enabled.
+func (d *decodeState) string(data []byte) string {
+	if d.opts.Borrow {
+		return *(*string)(unsafe.Pointer(&data))
+	}
+	return string(data)
+}
+
+// bytes returns data as a byte slice aliasing it if borrowing is enabled.
+func (d *decodeState) bytes(data []byte) []byte {
+	if d.opts.Borrow {
+		return data[:len(data):len(data)]
+	}
+	return append([]byte{}, data...)
+}
+
+// scanner walks the tnetstrings in a dictionary or list payload in place.
+type scanner struct {
+	data []byte
+	off  int64 // offset of data in the input
+}
+
+func (s *scanner) more() bool {
+	return len(s.data) > 0
+}
+
+// next cuts the next tnetstring off and returns its type char, payload and the offset of the payload.
+func (s *scanner) next() (byte, []byte, int64, error) {
+	payload, t, rest, pos, err := scan(s.data)
+	if err != nil {
+		return 0, nil, 0, &SyntaxError{Offset: s.off + int64(pos), Err: err}
+	}
+	off := s.off + int64(len(s.data)-len(rest)-len(payload)-1)
+	s.off += int64(len(s.data) - len(rest))
+	s.data = rest
+	return t, payload, off, nil
+}
+
+// raw cuts the next tnetstring off and returns it as is along with its type char and offset.
+func (s *scanner) raw() ([]byte, byte, int64, error) {
+	data, start := s.data, s.off
+	t, _, _, err := s.next()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return data[:len(data)-len(s.data)], t, start, nil
+}
+
+// nextRaw is next which also returns the whole tnetstring as is.
+func (s *scanner) nextRaw() ([]byte, byte, []byte, int64, error) {
+	data := s.data
+	t, payload, off, err := s.next()
+	if err != nil {
+		return nil, 0, nil, 0, err
+	}
+	return data[:len(data)-len(s.data)], t, payload, off, nil
+}
+
+// key cuts the next dictionary key off and returns its type char, payload and the offset of the payload.
+func (s *scanner) key() (byte, []byte, int64, error) {
+	t, key, off, err := s.next()
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	if t != ',' && t != ';' {
+		return 0, nil, 0, &SyntaxError{Offset: off + int64(len(key)), Err: ErrNonStringKey}
+	}
+	return t, key, off, nil
+}
+
+// value decodes a value with dec. raw is the whole tnetstring of the type char t and the payload data.
+// An error other than a *SyntaxError, a *DecodeError or a *LimitError becomes a *DecodeError.
+func (d *decodeState) value(dec decoderFunc, raw []byte, t byte, data []byte, off int64, rv reflect.Value) error {
+	d.raw = raw
+	err := dec(d, t, data, off, rv)
+	switch err.(type) {
+	case nil, *SyntaxError, *DecodeError, *LimitError:
+		return err
+	}
+	return newDecodeError(err, t, off, rv.Type())
+}
+
+func newDecodeError(err error, t byte, off int64, typ reflect.Type) *DecodeError {
+	return &DecodeError{Offset: off, Type: typ, Actual: t, Expected: expectedTypeChars(typ), Err: err}
+}
+
+// expectedTypeChars returns the type chars which can be decoded into t.
+func expectedTypeChars(t reflect.Type) string {
+	const all = ",;#^!~}]"
+	switch t {
+	case numberType:
+		return "#^~"
+	case bigIntType:
+		return ",;#~"
+	case bigFloatType, bigRatType, timeType, durationType:
+		return ",;#^~"
+	}
+	p := reflect.PtrTo(t)
+	if t.Implements(unmarshalerType) || p.Implements(unmarshalerType) {
+		return all
+	}
+	var s string
+	switch t.Kind() {
+	case reflect.String:
+		s = ",;"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s = "#"
+	case reflect.Float32, reflect.Float64:
+		s = "#^"
+	case reflect.Bool:
+		s = "!"
+	case reflect.Map, reflect.Struct:
+		s = "}"
+	case reflect.Array, reflect.Slice:
+		s = "]"
+		if t.Elem().Kind() == reflect.Uint8 {
+			s = ",;]"
+		}
+	case reflect.Ptr:
+		s = strings.TrimSuffix(expectedTypeChars(t.Elem()), "~") + "~"
+	case reflect.Interface:
+		s = all
+	}
+	if !strings.HasPrefix(s, ",;") && (t.Implements(textUnmarshalerType) || p.Implements(textUnmarshalerType) ||
+		t.Implements(binaryUnmarshalerType) || p.Implements(binaryUnmarshalerType)) {
+		s = ",;" + s
+	}
+	return s
+}
+
+// decoderFunc decodes the tnetstring of type char t whose payload data is found at offset off
+// into rv which is of the type the function was built for.
+type decoderFunc func(d *decodeState, t byte, data []byte, off int64, rv reflect.Value) error
+
+var decoderCache sync.Map // map[reflect.Type]decoderFunc
+
+// typeDecoder returns the cached decoderFunc for t building it on the first call.
+func typeDecoder(t reflect.Type) decoderFunc {
+	if f, ok := decoderCache.Load(t); ok {
+		return f.(decoderFunc)
+	}
+
+	// Store an indirect function first so that recursive types can refer to themselves while being built.
+	var (
+		wg sync.WaitGroup
+		f  decoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := decoderCache.LoadOrStore(t, decoderFunc(func(d *decodeState, c byte, data []byte, off int64, rv reflect.Value) error {
+		wg.Wait()
+		return f(d, c, data, off, rv)
+	}))
+	if loaded {
+		return fi.(decoderFunc)
+	}
+
+	f = newTypeDecoder(t)
+	wg.Done()
+	decoderCache.Store(t, f)
+	return f
+}
+
+func newTypeDecoder(t reflect.Type) decoderFunc {
+	if dec := newNumberDecoder(t); dec != nil {
+		return dec
+	}
+	if dec := newTimeDecoder(t, nil); dec != nil {
+		return dec
+	}
+	var dec decoderFunc
+	switch t.Kind() {
+	case reflect.Ptr:
+		dec = newPtrDecoder(t)
+	case reflect.Struct:
+		dec = newStructDecoder(t)
+	default:
+		dec = decodeKind
+	}
+	if t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface {
+		if implementsUnmarshaler(reflect.PtrTo(t)) {
+			return newUnmarshalerDecoder(true, dec)
+		}
+		if implementsUnmarshaler(t) {
+			return newUnmarshalerDecoder(false, dec)
+		}
+	}
+	return dec
+}
+
+func implementsUnmarshaler(t reflect.Type) bool {
+	return t.Implements(unmarshalerType) || t.Implements(textUnmarshalerType) || t.Implements(binaryUnmarshalerType)
+}
+
+// newUnmarshalerDecoder returns a decoderFunc which calls the unmarshaler of the value, or of its address if addr is true.
+// encoding.TextUnmarshaler and encoding.BinaryUnmarshaler are called only for strings, otherwise it falls back to dec.
+func newUnmarshalerDecoder(addr bool, dec decoderFunc) decoderFunc {
+	return func(d *decodeState, t byte, data []byte, off int64, rv reflect.Value) error {
+		v := rv
+		if addr {
+			if !rv.CanAddr() {
+				return dec(d, t, data, off, rv)
+			}
+			v = rv.Addr()
+		}
+		switch u := v.Interface().(type) {
+		case Unmarshaler:
+			return u.UnmarshalTNetstring(d.raw)
+		case encoding.TextUnmarshaler:
+			if t == ',' || t == ';' {
+				return u.UnmarshalText(data)
+			}
+		case encoding.BinaryUnmarshaler:
+			if t == ',' || t == ';' {
+				return u.UnmarshalBinary(data)
+			}
+		}
+		return dec(d, t, data, off, rv)
+	}
+}
+
+// newPtrDecoder returns a decoderFunc which allocates the pointer if it's nil and decodes into the pointee.
+// Null sets the pointer to nil.
+func newPtrDecoder(t reflect.Type) decoderFunc {
+	return ptrDecoder(t, typeDecoder(t.Elem()))
+}
+
+// ptrDecoder returns a decoderFunc for the pointer type t which decodes into the pointee with elem.
+func ptrDecoder(t reflect.Type, elem decoderFunc) decoderFunc {
+	return func(d *decodeState, c byte, data []byte, off int64, rv reflect.Value) error {
+		if c == '~' && rv.CanSet() {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.New(t.Elem()))
+		}
+		return elem(d, c, data, off, rv.Elem())
+	}
+}
+
+// decodeKind decodes into rv according to the type char t and the kind of rv.
+func decodeKind(d *decodeState, t byte, data []byte, off int64, rv reflect.Value) error {
+	switch t {
+	case ',', ';':
+		return d.decodeString(t, data, rv)
+	case '#':
+		return d.decodeInteger(data, rv)
+	case '^':
+		return d.decodeFloat(data, rv)
+	case '!':
+		return decodeBool(data, rv)
+	case '~':
+		return decodeNull(data, rv)
+	case '}':
+		return d.decodeDictionary(data, off, rv)
+	case ']':
+		return d.decodeList(data, off, rv)
+	}
+	return ErrInvalidTypeChar(t)
+}
+
+// mapKey converts a dictionary key into a value of the map key type t.
+func (d *decodeState) mapKey(t reflect.Type, key []byte) (reflect.Value, error) {
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		k := reflect.New(t)
+		if err := k.Interface().(encoding.TextUnmarshaler).UnmarshalText(key); err != nil {
+			return reflect.Value{}, err
+		}
+		return k.Elem(), nil
+	}
+	if t.Kind() == reflect.String {
+		return reflect.ValueOf(d.string(key)).Convert(t), nil
+	}
+	return reflect.Value{}, ErrUnsupportedType{Type: t}
+}
+
+func (d *decodeState) decodeString(t byte, data []byte, rv reflect.Value) error {
 	switch rv.Kind() {
 	case reflect.Interface:
 		if rv.Type().NumMethod() != 0 {
 			return ErrUnsupportedType{Type: rv.Type()}
 		}
-		rv.Set(reflect.ValueOf(string(data)))
+		target := d.opts.ByteStrings
+		if t == ';' {
+			target = d.opts.TextStrings
+		}
+		if target == TargetBytes {
+			rv.Set(reflect.ValueOf(d.bytes(data)))
+		} else {
+			rv.Set(reflect.ValueOf(d.string(data)))
+		}
 		return nil
 	case reflect.String:
-		rv.SetString(string(data))
+		rv.SetString(d.string(data))
+		return nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() != reflect.Uint8 {
+			return ErrUnsupportedType{Type: rv.Type()}
+		}
+		rv.SetBytes(d.bytes(data))
+		return nil
+	case reflect.Array:
+		if rv.Type().Elem().Kind() != reflect.Uint8 {
+			return ErrUnsupportedType{Type: rv.Type()}
+		}
+		for i := 0; i < rv.Len(); i++ {
+			var b byte
+			if i < len(data) {
+				b = data[i]
+			}
+			rv.Index(i).SetUint(uint64(b))
+		}
 		return nil
 	default:
 		return ErrUnsupportedType{Type: rv.Type()}
 	}
 }
 
-func decodeInteger(data []byte, rv reflect.Value) error {
+func (d *decodeState) decodeInteger(data []byte, rv reflect.Value) error {
 	switch rv.Kind() {
 	case reflect.Interface:
 		if rv.Type().NumMethod() != 0 {
 			return ErrUnsupportedType{Type: rv.Type()}
 		}
+		if d.opts.UseNumber {
+			rv.Set(reflect.ValueOf(Number(data)))
+			return nil
+		}
 		i, err := strconv.ParseInt(string(data), 0, 64)
 		if err != nil {
 			return err
@@ -118,25 +717,38 @@ func decodeInteger(data []byte, rv reflect.Value) error {
 			return err
 		}
 		rv.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(string(data), 8*int(rv.Type().Size()))
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(f)
 	default:
 		return ErrUnsupportedType{Type: rv.Type()}
 	}
 	return nil
 }
 
-func decodeFloat(data []byte, rv reflect.Value) error {
+func (d *decodeState) decodeFloat(data []byte, rv reflect.Value) error {
 	switch rv.Kind() {
 	case reflect.Interface:
 		if rv.Type().NumMethod() != 0 {
 			return ErrUnsupportedType{Type: rv.Type()}
 		}
-		f, err := strconv.ParseFloat(string(data), 64)
+		if d.opts.UseNumber {
+			if _, err := d.parseFloat(data, 64); err != nil && !isRangeError(err) {
+				return err
+			}
+			rv.Set(reflect.ValueOf(Number(data)))
+			return nil
+		}
+		f, err := d.parseFloat(data, 64)
 		if err != nil {
 			return err
 		}
 		rv.Set(reflect.ValueOf(f))
 	case reflect.Float32, reflect.Float64:
-		f, err := strconv.ParseFloat(string(data), 8*int(rv.Type().Size()))
+		f, err := d.parseFloat(data, 8*int(rv.Type().Size()))
 		if err != nil {
 			return err
 		}
@@ -147,6 +759,18 @@ func decodeFloat(data []byte, rv reflect.Value) error {
 	return nil
 }
 
+// parseFloat parses a float payload applying the NonFinitePolicy.
+func (d *decodeState) parseFloat(data []byte, bitSize int) (float64, error) {
+	f, err := strconv.ParseFloat(string(data), bitSize)
+	if err != nil {
+		return 0, err
+	}
+	if (math.IsNaN(f) || math.IsInf(f, 0)) && d.opts.NonFinite != NonFiniteTokens {
+		return 0, ErrNonFinite
+	}
+	return f, nil
+}
+
 func decodeBool(data []byte, rv reflect.Value) error {
 	switch rv.Kind() {
 	case reflect.Interface:
@@ -175,138 +799,207 @@ func decodeNull(_ []byte, rv reflect.Value) error {
 	return nil
 }
 
-func decodeDictionary(data []byte, rv reflect.Value) error {
+func (d *decodeState) decodeDictionary(data []byte, off int64, rv reflect.Value) error {
+	if err := d.enter(off); err != nil {
+		return err
+	}
+	defer d.leave()
+
 	switch rv.Kind() {
 	case reflect.Interface:
 		if rv.Type().NumMethod() != 0 {
 			return ErrUnsupportedType{Type: rv.Type()}
 		}
-		return decodeDictionaryInterface(data, rv)
+		return d.decodeDictionaryInterface(data, off, rv)
 	case reflect.Map:
-		return decodeDictionaryMap(data, rv)
-	case reflect.Struct:
-		return decodeDictionaryStruct(data, rv)
+		return d.decodeDictionaryMap(data, off, rv)
 	default:
 		return ErrUnsupportedType{Type: rv.Type()}
 	}
 }
 
-func decodeDictionaryInterface(data []byte, rv reflect.Value) error {
-	m := reflect.ValueOf(map[string]interface{}{})
-	d := NewDecoder(bytes.NewReader(data))
-	var key string
-	var val interface{}
-	for d.More() {
-		if err := d.Decode(&key); err != nil {
-			return err
-		}
-		if err := d.Decode(&val); err != nil {
-			return err
-		}
-		m.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(val))
+func (d *decodeState) decodeDictionaryInterface(data []byte, off int64, rv reflect.Value) error {
+	m := reflect.New(interfaceMapType).Elem()
+	if err := d.decodeDictionaryMap(data, off, m); err != nil {
+		return err
 	}
 	rv.Set(m)
 	return nil
 }
 
-func decodeDictionaryMap(data []byte, rv reflect.Value) error {
+func (d *decodeState) decodeDictionaryMap(data []byte, off int64, rv reflect.Value) error {
 	m := reflect.MakeMap(rv.Type())
-	d := NewDecoder(bytes.NewReader(data))
-	var key string
-	var val interface{}
-	for d.More() {
-		if err := d.Decode(&key); err != nil {
+	dec := typeDecoder(rv.Type().Elem())
+	s := scanner{data: data, off: off}
+	for i := 0; s.more(); i++ {
+		if err := d.checkItems(i, s.off); err != nil {
 			return err
 		}
-		if err := d.Decode(&val); err != nil {
+		t, key, off, err := s.key()
+		if err != nil {
+			return err
+		}
+		if err := d.decodeMapIndex(dec, m, t, key, off, &s); err != nil {
 			return err
 		}
-		m.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(val))
 	}
 	rv.Set(m)
 	return nil
 }
 
-func decodeDictionaryStruct(data []byte, rv reflect.Value) error {
-	d := NewDecoder(bytes.NewReader(data))
-	tags := make(map[string]*tag, rv.NumField())
-	for i := 0; i < rv.NumField(); i++ {
-		tag := parseTag(rv.Type().Field(i))
-		if tag == nil {
-			continue
-		}
-		tags[tag.displayName] = tag
+// decodeMapIndex decodes the next tnetstring in s with dec and stores it in the map m under key
+// whose type char is c and whose offset is keyOff.
+func (d *decodeState) decodeMapIndex(dec decoderFunc, m reflect.Value, c byte, key []byte, keyOff int64, s *scanner) error {
+	k, err := d.mapKey(m.Type().Key(), key)
+	if err != nil {
+		return withPath(newDecodeError(err, c, keyOff, m.Type().Key()), keyElem(key))
 	}
-	for d.More() {
-		var key string
-		if err := d.Decode(&key); err != nil {
-			return err
-		}
+	raw, t, data, off, err := s.nextRaw()
+	if err != nil {
+		return withPath(err, keyElem(key))
+	}
+	val := reflect.New(m.Type().Elem()).Elem()
+	if err := d.value(dec, raw, t, data, off, val); err != nil {
+		return withPath(err, keyElem(key))
+	}
+	m.SetMapIndex(k, val)
+	return nil
+}
 
-		var val interface{}
-		if err := d.Decode(&val); err != nil {
+// newStructDecoder returns a decoderFunc which decodes dictionaries into structs of type t.
+func newStructDecoder(t reflect.Type) decoderFunc {
+	fields := cachedTypeFields(t)
+	var remain decoderFunc
+	if fields.remain != nil {
+		remain = typeDecoder(fields.remain.typ.Elem())
+	}
+	return func(d *decodeState, c byte, data []byte, off int64, rv reflect.Value) error {
+		if c != '}' {
+			return decodeKind(d, c, data, off, rv)
+		}
+		if err := d.enter(off); err != nil {
 			return err
 		}
+		defer d.leave()
+
+		s := scanner{data: data, off: off}
+		for i := 0; s.more(); i++ {
+			keyOff := s.off
+			if err := d.checkItems(i, keyOff); err != nil {
+				return err
+			}
+			kt, key, kOff, err := s.key()
+			if err != nil {
+				return err
+			}
 
-		rv.FieldByName(tags[key].name).Set(reflect.ValueOf(val))
+			if f, ok := fields.byName[string(key)]; ok {
+				fv, err := fieldByIndexAlloc(rv, f.index)
+				if err != nil {
+					return withPath(newDecodeError(err, c, off, rv.Type()), keyElem(key))
+				}
+				raw, t, data, off, err := s.nextRaw()
+				if err != nil {
+					return withPath(err, keyElem(key))
+				}
+				if err := d.value(f.decoder, raw, t, data, off, fv); err != nil {
+					return withPath(err, keyElem(key))
+				}
+				continue
+			}
+
+			switch {
+			case fields.remain != nil:
+				fv, err := fieldByIndexAlloc(rv, fields.remain.index)
+				if err != nil {
+					return withPath(newDecodeError(err, c, off, rv.Type()), keyElem(key))
+				}
+				if fv.IsNil() {
+					fv.Set(reflect.MakeMap(fv.Type()))
+				}
+				if err := d.decodeMapIndex(remain, fv, kt, key, kOff, &s); err != nil {
+					return err
+				}
+			case d.opts.DisallowUnknownFields:
+				err := ErrUnknownField{Key: string(key), Offset: keyOff}
+				return withPath(newDecodeError(err, c, keyOff, rv.Type()), keyElem(key))
+			default:
+				if _, _, _, err := s.next(); err != nil {
+					return withPath(err, keyElem(key))
+				}
+			}
+		}
+		return nil
 	}
-	return nil
 }
 
-func decodeList(data []byte, rv reflect.Value) error {
+func (d *decodeState) decodeList(data []byte, off int64, rv reflect.Value) error {
+	if err := d.enter(off); err != nil {
+		return err
+	}
+	defer d.leave()
+
 	switch rv.Kind() {
 	case reflect.Array:
-		return decodeListArray(data, rv)
+		return d.decodeListArray(data, off, rv)
 	case reflect.Interface:
 		if rv.Type().NumMethod() != 0 {
 			return ErrUnsupportedType{Type: rv.Type()}
 		}
-		return decodeListInterface(data, rv)
+		return d.decodeListInterface(data, off, rv)
 	case reflect.Slice:
-		return decodeListSlice(data, rv)
+		return d.decodeListSlice(data, off, rv)
 	default:
 		return ErrUnsupportedType{Type: rv.Type()}
 	}
 }
 
-func decodeListArray(data []byte, rv reflect.Value) error {
-	d := NewDecoder(bytes.NewReader(data))
+func (d *decodeState) decodeListArray(data []byte, off int64, rv reflect.Value) error {
+	dec := typeDecoder(rv.Type().Elem())
+	s := scanner{data: data, off: off}
 	for i := 0; i < rv.Len(); i++ {
-		if !d.More() {
+		if !s.more() {
 			rv.Index(i).Set(reflect.Zero(rv.Type().Elem()))
 			continue
 		}
-		if err := d.Decode(rv.Index(i).Addr().Interface()); err != nil {
-			return err
+		raw, t, data, off, err := s.nextRaw()
+		if err != nil {
+			return withPath(err, indexElem(i))
+		}
+		if err := d.value(dec, raw, t, data, off, rv.Index(i)); err != nil {
+			return withPath(err, indexElem(i))
 		}
 	}
 	return nil
 }
 
-func decodeListInterface(data []byte, rv reflect.Value) error {
-	s := reflect.MakeSlice(reflect.TypeOf([]interface{}{}), 0, strings.Count(string(data), ":"))
-	d := NewDecoder(bytes.NewReader(data))
-	var e interface{}
-	for d.More() {
-		if err := d.Decode(&e); err != nil {
-			return err
-		}
-		s = reflect.Append(s, reflect.ValueOf(e))
+func (d *decodeState) decodeListInterface(data []byte, off int64, rv reflect.Value) error {
+	s := reflect.New(interfaceSliceType).Elem()
+	if err := d.decodeListSlice(data, off, s); err != nil {
+		return err
 	}
 	rv.Set(s)
 	return nil
 }
 
-func decodeListSlice(data []byte, rv reflect.Value) error {
-	s := reflect.MakeSlice(rv.Type(), 0, strings.Count(string(data), ":"))
-	d := NewDecoder(bytes.NewReader(data))
-	var e interface{}
-	for d.More() {
-		if err := d.Decode(&e); err != nil {
+func (d *decodeState) decodeListSlice(data []byte, off int64, rv reflect.Value) error {
+	l := reflect.MakeSlice(rv.Type(), 0, 0)
+	dec := typeDecoder(rv.Type().Elem())
+	s := scanner{data: data, off: off}
+	for i := 0; s.more(); i++ {
+		if err := d.checkItems(i, s.off); err != nil {
 			return err
 		}
-		s = reflect.Append(s, reflect.ValueOf(e))
+		raw, t, data, off, err := s.nextRaw()
+		if err != nil {
+			return withPath(err, indexElem(i))
+		}
+		e := reflect.New(rv.Type().Elem()).Elem()
+		if err := d.value(dec, raw, t, data, off, e); err != nil {
+			return withPath(err, indexElem(i))
+		}
+		l = reflect.Append(l, e)
 	}
-	rv.Set(s)
+	rv.Set(l)
 	return nil
 }
@@ -3,9 +3,16 @@ package tnetstrings
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"io"
+	"math/big"
+	"net"
+	"net/url"
 	"reflect"
+	"runtime"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -43,7 +50,7 @@ func TestDecoder_Decode_string(t *testing.T) {
 			Reader: bufio.NewReader(bytes.NewReader([]byte(tc.in))),
 		}
 		var s string
-		if err := d.Decode(&s); err != nil && !reflect.DeepEqual(tc.err, err) {
+		if err := d.Decode(&s); err != nil && !isError(err, tc.err) {
 			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
 		}
 		if tc.out != s {
@@ -91,7 +98,7 @@ func TestDecoder_Decode_int(t *testing.T) {
 			Reader: bufio.NewReader(bytes.NewReader([]byte(tc.in))),
 		}
 		var i int
-		if err := d.Decode(&i); err != nil && !reflect.DeepEqual(tc.err, err) {
+		if err := d.Decode(&i); err != nil && !isError(err, tc.err) {
 			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
 		}
 		if tc.out != i {
@@ -139,7 +146,7 @@ func TestDecoder_Decode_uint(t *testing.T) {
 			Reader: bufio.NewReader(bytes.NewReader([]byte(tc.in))),
 		}
 		var i uint
-		if err := d.Decode(&i); err != nil && !reflect.DeepEqual(tc.err, err) {
+		if err := d.Decode(&i); err != nil && !isError(err, tc.err) {
 			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
 		}
 		if tc.out != i {
@@ -187,7 +194,7 @@ func TestDecoder_Decode_float32(t *testing.T) {
 			Reader: bufio.NewReader(bytes.NewReader([]byte(tc.in))),
 		}
 		var f float32
-		if err := d.Decode(&f); err != nil && !reflect.DeepEqual(tc.err, err) {
+		if err := d.Decode(&f); err != nil && !isError(err, tc.err) {
 			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
 		}
 		if tc.out != f {
@@ -235,7 +242,7 @@ func TestDecoder_Decode_bool(t *testing.T) {
 			Reader: bufio.NewReader(bytes.NewReader([]byte(tc.in))),
 		}
 		var b bool
-		if err := d.Decode(&b); err != nil && !reflect.DeepEqual(tc.err, err) {
+		if err := d.Decode(&b); err != nil && !isError(err, tc.err) {
 			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
 		}
 		if tc.out != b {
@@ -273,7 +280,7 @@ func TestDecoder_Decode_null(t *testing.T) {
 			Reader: bufio.NewReader(bytes.NewReader([]byte(tc.in))),
 		}
 		var p *int
-		if err := d.Decode(&p); err != nil && !reflect.DeepEqual(tc.err, err) {
+		if err := d.Decode(&p); err != nil && !isError(err, tc.err) {
 			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
 		}
 		if tc.out != p {
@@ -318,7 +325,7 @@ func TestDecoder_Decode_map(t *testing.T) {
 			Reader: bufio.NewReader(bytes.NewReader([]byte(tc.in))),
 		}
 		var m map[string]interface{}
-		if err := d.Decode(&m); err != nil && !reflect.DeepEqual(tc.err, err) {
+		if err := d.Decode(&m); err != nil && !isError(err, tc.err) {
 			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
 		}
 		if !reflect.DeepEqual(tc.out, m) {
@@ -374,7 +381,7 @@ func TestDecoder_Decode_struct(t *testing.T) {
 			Reader: bufio.NewReader(bytes.NewReader([]byte(tc.in))),
 		}
 		var s s
-		if err := d.Decode(&s); err != nil && !reflect.DeepEqual(tc.err, err) {
+		if err := d.Decode(&s); err != nil && !isError(err, tc.err) {
 			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
 		}
 		if !reflect.DeepEqual(tc.out, s) {
@@ -435,7 +442,7 @@ func TestDecoder_Decode_array(t *testing.T) {
 			Reader: bufio.NewReader(bytes.NewReader([]byte(tc.in))),
 		}
 		var a [2]string
-		if err := d.Decode(&a); err != nil && !reflect.DeepEqual(tc.err, err) {
+		if err := d.Decode(&a); err != nil && !isError(err, tc.err) {
 			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
 		}
 		if !reflect.DeepEqual(tc.out, a) {
@@ -497,7 +504,7 @@ func TestDecoder_Decode_slice(t *testing.T) {
 			Reader: bufio.NewReader(bytes.NewReader([]byte(tc.in))),
 		}
 		var a []string
-		if err := d.Decode(&a); err != nil && !reflect.DeepEqual(tc.err, err) {
+		if err := d.Decode(&a); err != nil && !isError(err, tc.err) {
 			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
 		}
 		if !reflect.DeepEqual(tc.out, a) {
@@ -560,7 +567,7 @@ func TestDecoder_Decode_interface(t *testing.T) {
 			Reader: bufio.NewReader(bytes.NewReader([]byte(tc.in))),
 		}
 		var i interface{}
-		if err := d.Decode(&i); err != nil && !reflect.DeepEqual(tc.err, err) {
+		if err := d.Decode(&i); err != nil && !isError(err, tc.err) {
 			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
 		}
 		if !reflect.DeepEqual(tc.out, i) {
@@ -568,3 +575,750 @@ func TestDecoder_Decode_interface(t *testing.T) {
 		}
 	}
 }
+
+func TestDecoder_Decode_unmarshaler(t *testing.T) {
+	type s struct {
+		ID testID
+		P  *testPoint
+	}
+
+	testCases := []struct {
+		title string
+		in    interface{}
+	}{
+		{
+			title: "value",
+			in:    testID(7),
+		},
+		{
+			title: "pointer",
+			in:    &testPoint{X: 1, Y: 2},
+		},
+		{
+			title: "struct fields",
+			in:    s{ID: 7, P: &testPoint{X: 1, Y: 2}},
+		},
+		{
+			title: "map values",
+			in:    map[string]testID{"foo": 3},
+		},
+		{
+			title: "slice elements",
+			in:    []testPoint{{X: 4, Y: 5}, {X: 6, Y: 7}},
+		},
+		{
+			title: "array elements",
+			in:    [2]testID{8, 9},
+		},
+	}
+
+	for _, tc := range testCases {
+		b, err := Marshal(tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := NewDecoder(bytes.NewReader(b))
+		out := reflect.New(reflect.TypeOf(tc.in))
+		if err := d.Decode(out.Interface()); err != nil {
+			t.Errorf("[%s] %v", tc.title, err)
+		}
+		if !reflect.DeepEqual(tc.in, out.Elem().Interface()) {
+			t.Errorf("[%s] expected: %#v, got: %#v", tc.title, tc.in, out.Elem().Interface())
+		}
+	}
+}
+
+func TestDecoder_Decode_textUnmarshaler(t *testing.T) {
+	type s struct {
+		IP  net.IP
+		Int *big.Int
+		URL url.URL
+	}
+
+	testCases := []struct {
+		title string
+		in    interface{}
+	}{
+		{
+			title: "net.IP",
+			in:    net.IPv4(127, 0, 0, 1),
+		},
+		{
+			title: "big.Int",
+			in:    big.NewInt(1234567890),
+		},
+		{
+			title: "binary unmarshaler",
+			in:    url.URL{Scheme: "http", Host: "example.com"},
+		},
+		{
+			title: "struct fields",
+			in: s{
+				IP:  net.IPv4(127, 0, 0, 1),
+				Int: big.NewInt(1234567890),
+				URL: url.URL{Scheme: "http", Host: "example.com"},
+			},
+		},
+		{
+			title: "text unmarshaler keys",
+			in:    map[testKey]interface{}{{a: "foo", b: "bar"}: int64(1)},
+		},
+	}
+
+	for _, tc := range testCases {
+		b, err := Marshal(tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := reflect.New(reflect.TypeOf(tc.in))
+		if err := Unmarshal(b, out.Interface()); err != nil {
+			t.Errorf("[%s] %v", tc.title, err)
+		}
+		if !reflect.DeepEqual(tc.in, out.Elem().Interface()) {
+			t.Errorf("[%s] expected: %#v, got: %#v", tc.title, tc.in, out.Elem().Interface())
+		}
+	}
+}
+
+func TestDecoder_Decode_typed(t *testing.T) {
+	type inner struct {
+		Name  string
+		Count int
+	}
+	type outer struct {
+		Inner  inner
+		Ptr    *inner
+		Items  []inner
+		Counts map[string]int
+		Nested map[string][]int
+		Small  int8
+		Ratio  float64
+		Any    interface{}
+	}
+
+	testCases := []struct {
+		title string
+		in    interface{}
+	}{
+		{
+			title: "slice of int",
+			in:    []int{1, 2, 3},
+		},
+		{
+			title: "map of int",
+			in:    map[string]int{"foo": 1, "bar": 2},
+		},
+		{
+			title: "slice of struct",
+			in:    []inner{{Name: "foo", Count: 1}, {Name: "bar", Count: 2}},
+		},
+		{
+			title: "pointer to int",
+			in:    func() *int { i := 1; return &i }(),
+		},
+		{
+			title: "nested struct",
+			in: outer{
+				Inner:  inner{Name: "foo", Count: 1},
+				Ptr:    &inner{Name: "bar", Count: 2},
+				Items:  []inner{{Name: "baz", Count: 3}},
+				Counts: map[string]int{"foo": 1},
+				Nested: map[string][]int{"foo": {1, 2}},
+				Small:  -8,
+				Ratio:  0.5,
+				Any:    map[string]interface{}{"foo": nil},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		b, err := Marshal(tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := reflect.New(reflect.TypeOf(tc.in))
+		if err := Unmarshal(b, out.Interface()); err != nil {
+			t.Errorf("[%s] %v", tc.title, err)
+		}
+		if !reflect.DeepEqual(tc.in, out.Elem().Interface()) {
+			t.Errorf("[%s] expected: %#v, got: %#v", tc.title, tc.in, out.Elem().Interface())
+		}
+	}
+}
+
+func TestDecoder_Decode_numericConversion(t *testing.T) {
+	testCases := []struct {
+		title string
+		in    string
+		out   interface{}
+		err   error
+	}{
+		{
+			title: "int32",
+			in:    "3:123#",
+			out:   int32(123),
+		},
+		{
+			title: "uint16",
+			in:    "5:65535#",
+			out:   uint16(65535),
+		},
+		{
+			title: "float64",
+			in:    "3:123#",
+			out:   float64(123),
+		},
+		{
+			title: "int8 overflow",
+			in:    "3:300#",
+			out:   int8(127),
+			err:   &strconv.NumError{Func: "ParseInt", Num: "300", Err: strconv.ErrRange},
+		},
+	}
+
+	for _, tc := range testCases {
+		out := reflect.New(reflect.TypeOf(tc.out))
+		if err := Unmarshal([]byte(tc.in), out.Interface()); !isError(err, tc.err) {
+			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
+		}
+		if tc.err == nil && !reflect.DeepEqual(tc.out, out.Elem().Interface()) {
+			t.Errorf("[%s] expected: %#v, got: %#v", tc.title, tc.out, out.Elem().Interface())
+		}
+	}
+}
+
+func TestDecoder_Decode_unknownFields(t *testing.T) {
+	type s struct {
+		Known int
+	}
+	type r struct {
+		Known int
+		Rest  map[string]interface{} `tnetstrings:",remain"`
+	}
+
+	in := "26:5:Known,1:1#7:Unknown,1:2#}"
+
+	var skipped s
+	if err := NewDecoder(bytes.NewReader([]byte(in))).Decode(&skipped); err != nil {
+		t.Error(err)
+	}
+	if expected := (s{Known: 1}); expected != skipped {
+		t.Errorf("expected: %#v, got: %#v", expected, skipped)
+	}
+
+	d := NewDecoder(bytes.NewReader([]byte(in)))
+	d.DisallowUnknownFields()
+	var disallowed s
+	err := d.Decode(&disallowed)
+	if expected := (ErrUnknownField{Key: "Unknown", Offset: 15}); !errors.Is(err, expected) {
+		t.Errorf("expected: %v, got: %v", expected, err)
+	}
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Offset != 15 || de.Actual != '}' {
+		t.Errorf("expected a *DecodeError of } at offset 15, got: %#v", err)
+	}
+
+	d = NewDecoder(bytes.NewReader([]byte("3:abc," + "35:5:Outer,23:5:Known,1:1#5:Other,0:~}}")))
+	d.DisallowUnknownFields()
+	var str string
+	if err := d.Decode(&str); err != nil {
+		t.Error(err)
+	}
+	var nested struct {
+		Outer s
+	}
+	if err, expected := d.Decode(&nested), (ErrUnknownField{Key: "Other", Offset: 32}); !errors.Is(err, expected) {
+		t.Errorf("expected: %v, got: %v", expected, err)
+	}
+
+	d = NewDecoder(bytes.NewReader([]byte(in)))
+	d.DisallowUnknownFields()
+	var remain r
+	if err := d.Decode(&remain); err != nil {
+		t.Error(err)
+	}
+	expected := r{Known: 1, Rest: map[string]interface{}{"Unknown": int64(2)}}
+	if !reflect.DeepEqual(expected, remain) {
+		t.Errorf("expected: %#v, got: %#v", expected, remain)
+	}
+
+	b, err := Marshal(remain)
+	if err != nil {
+		t.Error(err)
+	}
+	var roundTrip r
+	if err := Unmarshal(b, &roundTrip); err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(expected, roundTrip) {
+		t.Errorf("expected: %#v, got: %#v", expected, roundTrip)
+	}
+
+	shadowed := r{Known: 1, Rest: map[string]interface{}{"Known": 2, "Unknown": 3}}
+	out := "26:5:Known,1:1#7:Unknown,1:3#}"
+	if b, err := Marshal(shadowed); err != nil || string(b) != out {
+		t.Errorf("expected: %s, got: %s, %v", out, b, err)
+	}
+}
+
+func TestDecoder_Decode_embedded(t *testing.T) {
+	in := testMessage{
+		testHeader: testHeader{ID: 1, Kind: "kind"},
+		Trace:      &Trace{Trace: "trace"},
+		Body:       "body",
+		Meta:       testMeta{Source: "source"},
+	}
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out testMessage
+	if err := Unmarshal(b, &out); err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("expected: %#v, got: %#v", in, out)
+	}
+}
+
+func BenchmarkDecoder_Decode_struct(b *testing.B) {
+	data, err := Marshal(&benchmarkOrderValue)
+	if err != nil {
+		b.Fatal(err)
+	}
+	r := bytes.NewReader(data)
+	d := NewDecoder(r)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Reset(data)
+		d.Reset(r)
+		var o benchmarkOrder
+		if err := d.Decode(&o); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecoder_Decode_recursive(t *testing.T) {
+	type node struct {
+		Name     string
+		Children []node
+		Next     *node
+	}
+
+	in := node{
+		Name: "root",
+		Children: []node{
+			{Name: "a", Children: []node{}, Next: &node{Name: "b", Children: []node{}}},
+		},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, err := Marshal(in)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			var out node
+			if err := Unmarshal(b, &out); err != nil {
+				t.Error(err)
+			}
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("expected: %#v, got: %#v", in, out)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDecoderOptions_Unmarshal_borrow(t *testing.T) {
+	type s struct {
+		Name string
+		Data []byte
+	}
+
+	in := []byte("26:4:Name,3:foo,4:Data,3:bar,}")
+
+	var copied s
+	if err := (DecoderOptions{}).Unmarshal(in, &copied); err != nil {
+		t.Fatal(err)
+	}
+	var borrowed s
+	if err := (DecoderOptions{Borrow: true}).Unmarshal(in, &borrowed); err != nil {
+		t.Fatal(err)
+	}
+
+	copy(in, "26:4:Name,3:FOO,4:Data,3:BAR,}")
+
+	if expected := (s{Name: "foo", Data: []byte("bar")}); !reflect.DeepEqual(expected, copied) {
+		t.Errorf("expected: %#v, got: %#v", expected, copied)
+	}
+	if expected := (s{Name: "FOO", Data: []byte("BAR")}); !reflect.DeepEqual(expected, borrowed) {
+		t.Errorf("expected: %#v, got: %#v", expected, borrowed)
+	}
+}
+
+func TestDecoderOptions_NewDecoder_borrow(t *testing.T) {
+	d := DecoderOptions{Borrow: true}.NewDecoder(bytes.NewReader([]byte("3:foo,3:bar,")))
+	var foo, bar string
+	if err := d.Decode(&foo); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Decode(&bar); err != nil {
+		t.Fatal(err)
+	}
+	if foo != "foo" || bar != "bar" {
+		t.Errorf("expected: foo bar, got: %s %s", foo, bar)
+	}
+}
+
+func BenchmarkUnmarshal_struct(b *testing.B) {
+	benchmarkUnmarshal(b, DecoderOptions{})
+}
+
+func BenchmarkUnmarshal_struct_borrow(b *testing.B) {
+	benchmarkUnmarshal(b, DecoderOptions{Borrow: true})
+}
+
+func benchmarkUnmarshal(b *testing.B, opts DecoderOptions) {
+	data, err := Marshal(&benchmarkOrderValue)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var o benchmarkOrder
+		if err := opts.Unmarshal(data, &o); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecoder_Decode_errors(t *testing.T) {
+	type item struct {
+		N int
+	}
+	type order struct {
+		Items []item
+	}
+
+	var o order
+	err := Unmarshal([]byte("34:5:Items,22:8:1:N,1:1#}8:1:N,1:x,}]}"), &o)
+	expected := &DecodeError{
+		Offset:   33,
+		Path:     "Items[1].N",
+		Type:     reflect.TypeOf(0),
+		Actual:   ',',
+		Expected: "#",
+		Err:      ErrUnsupportedType{Type: reflect.TypeOf(0)},
+	}
+	if !reflect.DeepEqual(expected, err) {
+		t.Errorf("expected: %v, got: %v", expected, err)
+	}
+	var de *DecodeError
+	if !errors.As(err, &de) || !errors.Is(err, ErrUnsupportedType{Type: reflect.TypeOf(0)}) {
+		t.Errorf("expected a *DecodeError wrapping ErrUnsupportedType, got: %v", err)
+	}
+	if msg := "cannot decode , into int at Items[1].N (offset 33): unsupported type: int"; err.Error() != msg {
+		t.Errorf("expected: %s, got: %s", msg, err)
+	}
+
+	var m map[string]interface{}
+	d := NewDecoder(bytes.NewReader([]byte("13:3:a.b,4:1:1?]}")))
+	err = d.Decode(&m)
+	if expected := (&SyntaxError{Offset: 14, Path: `["a.b"][0]`, Err: ErrInvalidTypeChar('?')}); !reflect.DeepEqual(expected, err) {
+		t.Errorf("expected: %v, got: %v", expected, err)
+	}
+	if !errors.Is(err, ErrInvalidTypeChar('?')) {
+		t.Errorf("expected: %v, got: %v", ErrInvalidTypeChar('?'), err)
+	}
+}
+
+func TestUnmarshal_invalid(t *testing.T) {
+	var s string
+	tests := []struct {
+		val      interface{}
+		expected error
+	}{
+		{nil, ErrInvalidUnmarshal{}},
+		{s, ErrInvalidUnmarshal{Type: reflect.TypeOf("")}},
+		{(*string)(nil), ErrInvalidUnmarshal{Type: reflect.TypeOf(&s)}},
+	}
+	for _, test := range tests {
+		if err := Unmarshal([]byte("3:abc,"), test.val); !reflect.DeepEqual(test.expected, err) {
+			t.Errorf("%#v: expected: %v, got: %v", test.val, test.expected, err)
+		}
+		d := NewDecoder(bytes.NewReader([]byte("3:abc,")))
+		if err := d.Decode(test.val); !reflect.DeepEqual(test.expected, err) {
+			t.Errorf("%#v: expected: %v, got: %v", test.val, test.expected, err)
+		}
+	}
+}
+
+func TestDecoderOptions_strings(t *testing.T) {
+	tests := []struct {
+		opts     DecoderOptions
+		data     string
+		expected interface{}
+	}{
+		{DecoderOptions{}, "3:abc,", "abc"},
+		{DecoderOptions{}, "3:abc;", "abc"},
+		{DecoderOptions{ByteStrings: TargetBytes}, "3:abc,", []byte("abc")},
+		{DecoderOptions{ByteStrings: TargetBytes}, "3:abc;", "abc"},
+		{DecoderOptions{TextStrings: TargetBytes}, "3:abc,", "abc"},
+		{DecoderOptions{TextStrings: TargetBytes}, "3:abc;", []byte("abc")},
+		{
+			DecoderOptions{ByteStrings: TargetBytes},
+			"23:3:bin,2:\x00\xff,3:txt;3:txt;}",
+			map[string]interface{}{"bin": []byte{0, 0xff}, "txt": "txt"},
+		},
+	}
+	for _, test := range tests {
+		var v interface{}
+		if err := test.opts.Unmarshal([]byte(test.data), &v); err != nil {
+			t.Errorf("%+v %q: %v", test.opts, test.data, err)
+		}
+		if !reflect.DeepEqual(test.expected, v) {
+			t.Errorf("%+v %q: expected: %#v, got: %#v", test.opts, test.data, test.expected, v)
+		}
+	}
+
+	in := []byte{0, 1, 0xfe, 0xff}
+	for _, opts := range []EncoderOptions{{}, {Dialect: TNetstring3}} {
+		b, err := opts.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []byte
+		if err := Unmarshal(b, &out); err != nil || !bytes.Equal(in, out) {
+			t.Errorf("expected: %v, got: %v, %v", in, out, err)
+		}
+	}
+
+	sum := [4]byte{0xde, 0xad, 0xbe, 0xef}
+	b, err := Marshal(sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out [4]byte
+	if err := Unmarshal(b, &out); err != nil || out != sum {
+		t.Errorf("expected: %v, got: %v, %v", sum, out, err)
+	}
+	out = [4]byte{1, 2, 3, 4}
+	if err := Unmarshal([]byte("2:ab,"), &out); err != nil || out != [4]byte{'a', 'b', 0, 0} {
+		t.Errorf("expected: %v, got: %v, %v", [4]byte{'a', 'b', 0, 0}, out, err)
+	}
+	if err := Unmarshal([]byte("6:abcdef,"), &out); err != nil || out != [4]byte{'a', 'b', 'c', 'd'} {
+		t.Errorf("expected: %v, got: %v, %v", [4]byte{'a', 'b', 'c', 'd'}, out, err)
+	}
+
+	type myByte byte
+	named := [3]myByte{'x', 'y', 'z'}
+	if b, err = Marshal(named); err != nil {
+		t.Fatal(err)
+	}
+	var namedOut [3]myByte
+	if err := Unmarshal(b, &namedOut); err != nil || namedOut != named {
+		t.Errorf("expected: %v, got: %v, %v", named, namedOut, err)
+	}
+}
+
+func TestDecoderOptions_limits(t *testing.T) {
+	tests := []struct {
+		opts     DecoderOptions
+		data     string
+		expected error
+	}{
+		{DecoderOptions{MaxValueSize: 5}, "5:abcde,", nil},
+		{DecoderOptions{MaxValueSize: 5}, "6:abcdef,", &LimitError{Offset: 2, Err: ErrMaxValueSize}},
+		{DecoderOptions{MaxDepth: 3}, "6:3:0:]]]", nil},
+		{DecoderOptions{MaxDepth: 2}, "6:3:0:]]]", &LimitError{Offset: 6, Path: "[0][0]", Err: ErrMaxDepth}},
+		{DecoderOptions{MaxDepth: 1}, "7:1:a,0:}}", &LimitError{Offset: 8, Path: "a", Err: ErrMaxDepth}},
+		{DecoderOptions{MaxTotalBytes: 7}, "4:abcd,", nil},
+		{DecoderOptions{MaxTotalBytes: 6}, "4:abcd,", &LimitError{Offset: 6, Err: ErrMaxTotalBytes}},
+		{DecoderOptions{MaxContainerItems: 3}, "12:1:1#1:2#1:3#]", nil},
+		{DecoderOptions{MaxContainerItems: 2}, "12:1:1#1:2#1:3#]", &LimitError{Offset: 11, Err: ErrMaxContainerItems}},
+		{DecoderOptions{MaxContainerItems: 1}, "16:1:a,1:1#1:b,1:2#}", &LimitError{Offset: 11, Err: ErrMaxContainerItems}},
+		{DecoderOptions{Strict: true, MaxDepth: 2}, "6:3:0:]]]", &LimitError{Offset: 6, Path: "[0][0]", Err: ErrMaxDepth}},
+		{DecoderOptions{Strict: true, MaxDepth: 2}, "11:8:5:01:1#]]]", &LimitError{Offset: 7, Path: "[0][0]", Err: ErrMaxDepth}},
+		{DecoderOptions{Strict: true, MaxContainerItems: 2}, "13:1:1#1:2#01:3#]", &LimitError{Offset: 11, Err: ErrMaxContainerItems}},
+	}
+	for _, test := range tests {
+		var v interface{}
+		if err := test.opts.Unmarshal([]byte(test.data), &v); !reflect.DeepEqual(test.expected, err) {
+			t.Errorf("%+v %q: expected: %v, got: %v", test.opts, test.data, test.expected, err)
+		}
+	}
+}
+
+func TestDecoderOptions_limits_alloc(t *testing.T) {
+	type elem struct{ A [1024]byte }
+	data, err := Marshal([]map[string]string{{"A": strings.Repeat(":", 100000)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DecoderOptions{MaxValueSize: 200000, MaxTotalBytes: 200000}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	var v []elem
+	if err := opts.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("expected at most 1 MiB, got: %d bytes", n)
+	}
+	if len(v) != 1 || v[0].A[0] != ':' {
+		t.Errorf("expected one element, got: %d", len(v))
+	}
+}
+
+func TestDecoder_Decode_limits(t *testing.T) {
+	var s string
+	d := DecoderOptions{MaxValueSize: 1 << 20}.NewDecoder(bytes.NewReader([]byte("999999999:")))
+	if err, expected := d.Decode(&s), (&LimitError{Offset: 10, Err: ErrMaxValueSize}); !reflect.DeepEqual(expected, err) {
+		t.Errorf("expected: %v, got: %v", expected, err)
+	}
+
+	d = DecoderOptions{MaxTotalBytes: 10}.NewDecoder(bytes.NewReader([]byte("4:abcd,4:abcd,")))
+	if err := d.Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	if err, expected := d.Decode(&s), (&LimitError{Offset: 9, Err: ErrMaxTotalBytes}); !reflect.DeepEqual(expected, err) {
+		t.Errorf("expected: %v, got: %v", expected, err)
+	}
+
+	d = DecoderOptions{MaxDepth: 1}.NewDecoder(bytes.NewReader([]byte("6:3:0:]]]")))
+	if _, err := d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Token(); !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("expected: %v, got: %v", ErrMaxDepth, err)
+	}
+
+	var v interface{}
+	d = DecoderOptions{MaxDepth: 2}.NewDecoder(bytes.NewReader([]byte("6:3:0:]]]")))
+	if _, err := d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if err, expected := d.Decode(&v), (&LimitError{Offset: 6, Path: "[0]", Err: ErrMaxDepth}); !reflect.DeepEqual(expected, err) {
+		t.Errorf("expected: %v, got: %v", expected, err)
+	}
+
+	d = DecoderOptions{MaxContainerItems: 2}.NewDecoder(bytes.NewReader([]byte("12:1:1#1:2#1:3#]")))
+	for i := 0; i < 3; i++ {
+		if _, err := d.Token(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.Token(); !reflect.DeepEqual(&LimitError{Offset: 11, Err: ErrMaxContainerItems}, err) {
+		t.Errorf("expected: %v, got: %v", ErrMaxContainerItems, err)
+	}
+
+	d = DecoderOptions{MaxContainerItems: 1}.NewDecoder(bytes.NewReader([]byte("16:1:a,1:1#1:b,1:2#}")))
+	if _, err := d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Skip(); !reflect.DeepEqual(&LimitError{Offset: 11, Err: ErrMaxContainerItems}, err) {
+		t.Errorf("expected: %v, got: %v", ErrMaxContainerItems, err)
+	}
+
+	d = DecoderOptions{MaxTotalBytes: 10}.NewDecoder(bytes.NewReader([]byte("5:abcde,")))
+	for i := 0; i < 2; i++ {
+		var s string
+		if err := d.Decode(&s); err != nil || s != "abcde" {
+			t.Errorf("[%d] expected: abcde, got: %s, %v", i, s, err)
+		}
+		d.Reset(bytes.NewReader([]byte("5:abcde,")))
+	}
+}
+
+func TestDecoderOptions_Strict(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected error
+	}{
+		{"4:true!", nil},
+		{"1:1!", &SyntaxError{Offset: 2, Err: ErrInvalidBoolean}},
+		{"4:TRUE!", &SyntaxError{Offset: 2, Err: ErrInvalidBoolean}},
+		{"2:-5#", nil},
+		{"4:0x1f#", &SyntaxError{Offset: 2, Err: ErrInvalidInteger}},
+		{"3:017#", &SyntaxError{Offset: 2, Err: ErrInvalidInteger}},
+		{"0:#", &SyntaxError{Offset: 2, Err: ErrInvalidInteger}},
+		{"0:~", nil},
+		{"3:abc~", &SyntaxError{Offset: 2, Err: ErrNonEmptyNull}},
+		{"2:\xff\xfe,", nil},
+		{"2:\xff\xfe;", &SyntaxError{Offset: 2, Err: ErrInvalidUTF8}},
+		{"8:2:\xff\xfe;0:~}", &SyntaxError{Offset: 4, Path: "\xff\xfe", Err: ErrInvalidUTF8}},
+		{"01:a,", &SyntaxError{Offset: 0, Err: ErrNonCanonicalSize}},
+		{"9:1:a,01:1#}", &SyntaxError{Offset: 6, Path: "a", Err: ErrNonCanonicalSize}},
+		{"8:1:1#1:1#}", &SyntaxError{Offset: 5, Err: ErrNonStringKey}},
+		{"11:4:true!1:t!]", &SyntaxError{Offset: 12, Path: "[1]", Err: ErrInvalidBoolean}},
+	}
+	for _, test := range tests {
+		var v interface{}
+		if err := (DecoderOptions{Strict: true}).Unmarshal([]byte(test.data), &v); !reflect.DeepEqual(test.expected, err) {
+			t.Errorf("%q: expected: %v, got: %v", test.data, test.expected, err)
+		}
+		d := DecoderOptions{Strict: true}.NewDecoder(bytes.NewReader([]byte(test.data)))
+		if err := d.Decode(&v); !reflect.DeepEqual(test.expected, err) {
+			t.Errorf("%q: expected: %v, got: %v", test.data, test.expected, err)
+		}
+		d = DecoderOptions{Strict: true}.NewDecoder(bytes.NewReader([]byte(test.data)))
+		if _, err := d.Next(); !reflect.DeepEqual(test.expected, err) {
+			t.Errorf("%q: Next: expected: %v, got: %v", test.data, test.expected, err)
+		}
+	}
+
+	for _, data := range []string{"01:a,", "01:1!"} {
+		d := DecoderOptions{Strict: true}.NewDecoder(bytes.NewReader([]byte(data)))
+		if err := d.Skip(); !reflect.DeepEqual(&SyntaxError{Offset: 0, Err: ErrNonCanonicalSize}, err) {
+			t.Errorf("%q: Skip: expected: %v, got: %v", data, ErrNonCanonicalSize, err)
+		}
+	}
+
+	var b bool
+	if err := Unmarshal([]byte("1:1!"), &b); err != nil || !b {
+		t.Errorf("expected lenient decoding without Strict, got: %v, %v", b, err)
+	}
+
+	d := DecoderOptions{Strict: true}.NewDecoder(bytes.NewReader([]byte("4:1:t!]")))
+	if _, err := d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Token(); !errors.Is(err, ErrInvalidBoolean) {
+		t.Errorf("expected: %v, got: %v", ErrInvalidBoolean, err)
+	}
+
+	d = DecoderOptions{Strict: true}.NewDecoder(bytes.NewReader([]byte("8:1:1#1:2#}")))
+	if _, err := d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Token(); !errors.Is(err, ErrNonStringKey) {
+		t.Errorf("expected: %v, got: %v", ErrNonStringKey, err)
+	}
+}
+
+// isError reports whether err or any error it wraps is deeply equal to target.
+func isError(err, target error) bool {
+	if target == nil {
+		return err == nil
+	}
+	for ; err != nil; err = errors.Unwrap(err) {
+		if reflect.DeepEqual(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,266 @@
+package tnetstrings
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// Set returns a copy of data with the value at path replaced by the tnetstring of val.
+// A missing dictionary key is added at the end of the dictionary and a list index `-`, or the length of the list,
+// appends an item. The path is in the syntax of Query without wildcards.
+func Set(data []byte, path string, val interface{}) ([]byte, error) {
+	return Patch{{Op: OpSet, Path: path, Value: val}}.Apply(data)
+}
+
+// Delete returns a copy of data without the dictionary entry or the list item at path.
+func Delete(data []byte, path string) ([]byte, error) {
+	return Patch{{Op: OpDelete, Path: path}}.Apply(data)
+}
+
+// Op is the kind of an Operation.
+type Op string
+
+// Ops resemble the ones of JSON Patch.
+const (
+	// OpSet replaces the value at Path or adds it like Set.
+	OpSet Op = "set"
+
+	// OpAdd inserts Value at Path. For a list the items from the index on are shifted.
+	OpAdd Op = "add"
+
+	// OpReplace replaces the existing value at Path.
+	OpReplace Op = "replace"
+
+	// OpDelete removes the value at Path like Delete.
+	OpDelete Op = "delete"
+
+	// OpTest fails the Patch with ErrTestFailed unless the value at Path is encoded same as Value.
+	OpTest Op = "test"
+)
+
+// Operation is an edit of an encoded document.
+type Operation struct {
+	Op    Op
+	Path  string
+	Value interface{}
+}
+
+// Patch is a list of operations applied in order.
+type Patch []Operation
+
+// Apply returns a copy of data with the operations applied. Every enclosing SIZE is fixed up while the rest of
+// the bytes are kept as is. If any of the operations fails, it returns the error and no result.
+func (p Patch) Apply(data []byte) ([]byte, error) {
+	if _, _, _, err := splitTop(data); err != nil {
+		return nil, err
+	}
+	for _, o := range p {
+		var err error
+		if data, err = o.apply(data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func (o Operation) apply(data []byte) ([]byte, error) {
+	segs, err := parsePath(o.Path)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range segs {
+		if s.wildcard {
+			return nil, ErrInvalidPath(o.Path)
+		}
+	}
+
+	var val []byte
+	switch o.Op {
+	case OpSet, OpAdd, OpReplace, OpTest:
+		if val, err = Marshal(o.Value); err != nil {
+			return nil, err
+		}
+	case OpDelete:
+	default:
+		return nil, ErrInvalidOp(o.Op)
+	}
+
+	if len(segs) == 0 {
+		switch o.Op {
+		case OpTest:
+			if !bytes.Equal(data, val) {
+				return nil, ErrTestFailed
+			}
+			return data, nil
+		case OpDelete:
+			return nil, ErrInvalidPath(o.Path)
+		default:
+			return val, nil
+		}
+	}
+
+	loc, err := locate(data, segs)
+	if err != nil {
+		return nil, err
+	}
+	if !loc.found && o.Op != OpSet && o.Op != OpAdd {
+		return nil, ErrNotFound
+	}
+
+	switch o.Op {
+	case OpTest:
+		if !bytes.Equal(data[loc.start:loc.end], val) {
+			return nil, ErrTestFailed
+		}
+		return data, nil
+	case OpDelete:
+		return rewrite(data, loc.parents, loc.key, loc.end, nil)
+	case OpAdd:
+		if loc.t == ']' {
+			return rewrite(data, loc.parents, loc.start, loc.start, val)
+		}
+	}
+	if !loc.found {
+		if loc.t == '}' {
+			key, err := Marshal(segs[len(segs)-1].key)
+			if err != nil {
+				return nil, err
+			}
+			val = append(key, val...)
+		}
+		return rewrite(data, loc.parents, loc.start, loc.start, val)
+	}
+	return rewrite(data, loc.parents, loc.start, loc.end, val)
+}
+
+// location is where a path leads in an encoded document.
+type location struct {
+	// parents are the offsets of the enclosing tnetstrings from the outermost one.
+	parents []int
+
+	// t is the type char of the innermost enclosing tnetstring.
+	t byte
+
+	// found is true if the path exists. Then the value is data[start:end] and if it's a dictionary entry
+	// data[key:start] is its key. Otherwise start is where the value is to be inserted.
+	found           bool
+	key, start, end int
+}
+
+func locate(data []byte, segs []segment) (location, error) {
+	var (
+		loc   location
+		elems []string // the path elements followed so far
+	)
+	off, end := 0, len(data)
+	for i, seg := range segs {
+		s := scanner{data: data[off:end], off: int64(off)}
+		t, payload, pOff, err := s.next()
+		if err != nil {
+			return location{}, withElems(err, elems)
+		}
+		loc.parents = append(loc.parents, off)
+		loc.t = t
+
+		loc.found = false
+		s = scanner{data: payload, off: pOff}
+		switch t {
+		case '}':
+			for s.more() {
+				key := int(s.off)
+				_, k, _, err := s.key()
+				if err != nil {
+					return location{}, withElems(err, elems)
+				}
+				v, _, vOff, err := s.raw()
+				if err != nil {
+					return location{}, withElems(withPath(err, keyElem(k)), elems)
+				}
+				if string(k) == seg.key {
+					loc.found = true
+					loc.key, loc.start, loc.end = key, int(vOff), int(vOff)+len(v)
+					break
+				}
+			}
+		case ']':
+			n := -1
+			if seg.key != "-" {
+				if n, err = strconv.Atoi(seg.key); err != nil || n < 0 {
+					return location{}, ErrNotFound
+				}
+			}
+			j := 0
+			for ; s.more(); j++ {
+				start := int(s.off)
+				v, _, _, err := s.raw()
+				if err != nil {
+					return location{}, withElems(withPath(err, indexElem(j)), elems)
+				}
+				if j == n {
+					loc.found = true
+					loc.key, loc.start, loc.end = start, start, start+len(v)
+					break
+				}
+			}
+			if !loc.found && n > j {
+				return location{}, ErrNotFound
+			}
+		default:
+			return location{}, ErrTypeMismatch(t)
+		}
+
+		if !loc.found {
+			if i != len(segs)-1 {
+				return location{}, ErrNotFound
+			}
+			loc.key, loc.start, loc.end = end-1, end-1, end-1
+		}
+		elems = append(elems, childElem(t, seg.key))
+		off, end = loc.start, loc.end
+	}
+	return loc, nil
+}
+
+// withElems prepends the path elements to the path of err.
+func withElems(err error, elems []string) error {
+	for i := len(elems) - 1; i >= 0; i-- {
+		err = withPath(err, elems[i])
+	}
+	return err
+}
+
+// rewrite returns a copy of data with data[start:end] replaced by b and the SIZE of the parents fixed up.
+func rewrite(data []byte, parents []int, start, end int, b []byte) ([]byte, error) {
+	// Fix up from the innermost parent since a SIZE getting longer or shorter also changes the outer ones.
+	type prefix struct {
+		size int64
+		n    int
+	}
+	prefixes := make([]prefix, len(parents))
+	delta := len(b) - (end - start)
+	for i := len(parents) - 1; i >= 0; i-- {
+		size, n, err := parseSize(data[parents[i]:])
+		if err != nil {
+			return nil, err
+		}
+		s := int64(size) + int64(delta)
+		if s >= 1e9 {
+			return nil, ErrSizeLimitExceeded
+		}
+		prefixes[i] = prefix{size: s, n: n}
+		delta += len(strconv.FormatInt(s, 10)) + 1 - n
+	}
+
+	out := make([]byte, 0, len(data)+delta)
+	prev := 0
+	for i, p := range parents {
+		out = append(out, data[prev:p]...)
+		out = strconv.AppendInt(out, prefixes[i].size, 10)
+		out = append(out, ':')
+		prev = p + prefixes[i].n
+	}
+	out = append(out, data[prev:start]...)
+	out = append(out, b...)
+	out = append(out, data[end:]...)
+	return out, nil
+}
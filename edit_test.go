@@ -0,0 +1,176 @@
+package tnetstrings
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	testCases := []struct {
+		title string
+		path  string
+		val   interface{}
+		out   string
+		err   error
+	}{
+		{
+			title: "replace nested",
+			path:  "headers.user.id",
+			val:   12345,
+			out:   `129:7:headers,53:4:user,28:2:id,5:12345#4:name,5:alice,}7:a.b[c]",1:x,}5:items,50:13:4:name,3:foo,}13:4:name,3:bar,}12:5:other,1:1#}]}`,
+		},
+		{
+			title: "add key",
+			path:  "headers.user.admin",
+			val:   true,
+			out:   `141:7:headers,65:4:user,40:2:id,2:42#4:name,5:alice,5:admin,4:true!}7:a.b[c]",1:x,}5:items,50:13:4:name,3:foo,}13:4:name,3:bar,}12:5:other,1:1#}]}`,
+		},
+		{
+			title: "replace list item",
+			path:  "items[2]",
+			val:   RawMessage("0:~"),
+			out:   `113:7:headers,50:4:user,25:2:id,2:42#4:name,5:alice,}7:a.b[c]",1:x,}5:items,37:13:4:name,3:foo,}13:4:name,3:bar,}0:~]}`,
+		},
+		{
+			title: "append list item",
+			path:  "items.-",
+			val:   RawMessage("0:~"),
+			out:   `129:7:headers,50:4:user,25:2:id,2:42#4:name,5:alice,}7:a.b[c]",1:x,}5:items,53:13:4:name,3:foo,}13:4:name,3:bar,}12:5:other,1:1#}0:~]}`,
+		},
+		{
+			title: "root",
+			val:   RawMessage("0:~"),
+			out:   "0:~",
+		},
+		{
+			title: "missing parent",
+			path:  "headers.missing.id",
+			val:   1,
+			err:   ErrNotFound,
+		},
+		{
+			title: "wildcard",
+			path:  "items[*]",
+			val:   1,
+			err:   ErrInvalidPath("items[*]"),
+		},
+	}
+
+	for _, tc := range testCases {
+		data := []byte(testQueryData)
+		out, err := Set(data, tc.path, tc.val)
+		if err != tc.err {
+			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
+		}
+		if string(out) != tc.out {
+			t.Errorf("[%s] expected: %s, got: %s", tc.title, tc.out, out)
+		}
+		if string(data) != testQueryData {
+			t.Errorf("[%s] input was modified: %s", tc.title, data)
+		}
+		if err == nil {
+			if _, err := Parse(out); err != nil {
+				t.Errorf("[%s] %v", tc.title, err)
+			}
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	testCases := []struct {
+		title string
+		path  string
+		out   string
+		err   error
+	}{
+		{
+			title: "dictionary entry",
+			path:  "headers.user",
+			out:   `90:7:headers,14:7:a.b[c]",1:x,}5:items,50:13:4:name,3:foo,}13:4:name,3:bar,}12:5:other,1:1#}]}`,
+		},
+		{
+			title: "list item",
+			path:  "items[0]",
+			out:   `109:7:headers,50:4:user,25:2:id,2:42#4:name,5:alice,}7:a.b[c]",1:x,}5:items,33:13:4:name,3:bar,}12:5:other,1:1#}]}`,
+		},
+		{
+			title: "missing",
+			path:  "items[3]",
+			err:   ErrNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		out, err := Delete([]byte(testQueryData), tc.path)
+		if err != tc.err {
+			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
+		}
+		if string(out) != tc.out {
+			t.Errorf("[%s] expected: %s, got: %s", tc.title, tc.out, out)
+		}
+	}
+}
+
+func TestPatch_Apply(t *testing.T) {
+	in := "18:4:list,8:1:1#1:3#]}"
+
+	testCases := []struct {
+		title string
+		patch Patch
+		out   string
+		err   error
+	}{
+		{
+			title: "batch",
+			patch: Patch{
+				{Op: OpTest, Path: "list[0]", Value: 1},
+				{Op: OpAdd, Path: "list[1]", Value: 2},
+				{Op: OpAdd, Path: "list[-]", Value: 4},
+				{Op: OpReplace, Path: "list[0]", Value: 0},
+				{Op: OpAdd, Path: "name", Value: RawMessage("3:foo,")},
+				{Op: OpDelete, Path: "list[3]"},
+			},
+			out: "36:4:list,12:1:0#1:2#1:3#]4:name,3:foo,}",
+		},
+		{
+			title: "test failed",
+			patch: Patch{
+				{Op: OpDelete, Path: "list[0]"},
+				{Op: OpTest, Path: "list[0]", Value: 1},
+			},
+			err: ErrTestFailed,
+		},
+		{
+			title: "replace missing",
+			patch: Patch{
+				{Op: OpReplace, Path: "name", Value: 1},
+			},
+			err: ErrNotFound,
+		},
+		{
+			title: "invalid op",
+			patch: Patch{
+				{Op: "move", Path: "list"},
+			},
+			err: ErrInvalidOp("move"),
+		},
+	}
+
+	for _, tc := range testCases {
+		out, err := tc.patch.Apply([]byte(in))
+		if err != tc.err {
+			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
+		}
+		if string(out) != tc.out {
+			t.Errorf("[%s] expected: %s, got: %s", tc.title, tc.out, out)
+		}
+	}
+}
+
+func TestPatch_Apply_syntaxError(t *testing.T) {
+	data := []byte("25:7:headers,11:4:user,1:x?}}")
+	expected := &SyntaxError{Offset: 26, Path: "headers.user", Err: ErrInvalidTypeChar('?')}
+	if _, err := Set(data, "headers.user", 1); !reflect.DeepEqual(expected, err) {
+		t.Errorf("expected: %v, got: %v", expected, err)
+	}
+}
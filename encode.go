@@ -1,17 +1,102 @@
 package tnetstrings
 
 import (
-	"bytes"
-	"fmt"
+	"encoding"
 	"io"
+	"math"
 	"reflect"
 	"sort"
 	"strconv"
+	"sync"
 )
 
+// Marshaler is the interface implemented by types that can marshal themselves into a valid tnetstring.
+type Marshaler interface {
+	MarshalTNetstring() ([]byte, error)
+}
+
+var (
+	marshalerType       = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	binaryMarshalerType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	isZeroerType        = reflect.TypeOf((*isZeroer)(nil)).Elem()
+)
+
+// isZeroer is implemented by types such as time.Time which know better than reflect whether they are zero.
+type isZeroer interface {
+	IsZero() bool
+}
+
+// NonFinitePolicy is how NaN and ±Inf are handled. They're not part of the tnetstrings spec.
+type NonFinitePolicy int
+
+const (
+	// NonFiniteError makes encoding and decoding them fail with ErrNonFinite.
+	NonFiniteError NonFinitePolicy = iota
+
+	// NonFiniteTokens encodes them as floats of `NaN`, `+Inf` and `-Inf`, and accepts them on decoding.
+	NonFiniteTokens
+
+	// NonFiniteNull encodes them as null. On decoding it's the same as NonFiniteError.
+	NonFiniteNull
+)
+
+// Dialect is the flavor of tnetstrings for strings.
+type Dialect int
+
+const (
+	// Classic encodes both strings and byte slices as `,` following the original spec.
+	Classic Dialect = iota
+
+	// TNetstring3 encodes strings and the output of encoding.TextMarshaler as `;` so that they can be told from
+	// byte slices, which are still `,`.
+	TNetstring3
+)
+
+// EncoderOptions configures encoding. The zero value is the default behavior.
+type EncoderOptions struct {
+	// Dialect is the flavor of tnetstrings for strings.
+	Dialect Dialect
+
+	// FloatFormat is the format of strconv.FormatFloat used for floats along with FloatPrecision.
+	// Zero means the shortest representation which decodes to the same float, that is 'g' with the precision -1.
+	FloatFormat byte
+
+	// FloatPrecision is the precision of strconv.FormatFloat. It's used only if FloatFormat is set.
+	FloatPrecision int
+
+	// NonFinite is the policy for NaN and ±Inf.
+	NonFinite NonFinitePolicy
+
+	// TimeLayout is how time.Time and time.Duration are encoded unless a struct tag says otherwise.
+	TimeLayout TimeLayout
+}
+
+// NewEncoder returns a new Encoder with the options.
+func (o EncoderOptions) NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{Writer: w, opts: o}
+}
+
+// Marshal returns the tnetstring encoding of val with the options.
+func (o EncoderOptions) Marshal(val interface{}) ([]byte, error) {
+	return o.AppendMarshal(nil, val)
+}
+
+// AppendMarshal appends the tnetstring encoding of val with the options to dst and returns the extended buffer.
+func (o EncoderOptions) AppendMarshal(dst []byte, val interface{}) ([]byte, error) {
+	s := newEncodeState()
+	defer s.release()
+	s.opts = o
+	if err := s.marshal(reflect.ValueOf(val)); err != nil {
+		return dst, err
+	}
+	return append(dst, s.bytes()...), nil
+}
+
 // Encoder is a streaming tnetstrings encoder.
 type Encoder struct {
 	io.Writer
+	opts EncoderOptions
 }
 
 // NewEncoder returns a new Encoder instance.
@@ -21,111 +106,557 @@ func NewEncoder(w io.Writer) *Encoder {
 
 // Encode encodes a value into tnetstring.
 func (e *Encoder) Encode(val interface{}) error {
-	v := reflect.ValueOf(val)
-	switch v.Kind() {
-	case reflect.String:
-		s := val.(string)
-		// _, err := fmt.Fprintf(e, "%d:%s;", len(d), d)
-		d := []byte(s)
-		_, err := fmt.Fprintf(e, "%d:", len(d))
-		if err != nil {
-			return err
+	s := newEncodeState()
+	defer s.release()
+	s.opts = e.opts
+	if err := s.marshal(reflect.ValueOf(val)); err != nil {
+		return err
+	}
+	_, err := e.Write(s.bytes())
+	return err
+}
+
+// encodeState is the buffer a value is encoded into.
+// It's filled from the end towards the beginning so that the size of a dictionary or a list is already known
+// when its prefix is written. This way every byte is written exactly once regardless of the nesting depth.
+type encodeState struct {
+	buf  []byte
+	off  int // the encoded data is buf[off:]
+	opts EncoderOptions
+}
+
+// maxPooledSize is the capacity above which a buffer is not returned to the pool so that one huge value
+// doesn't pin its memory forever.
+const maxPooledSize = 1 << 16
+
+var encodeStatePool sync.Pool
+
+func newEncodeState() *encodeState {
+	if s, ok := encodeStatePool.Get().(*encodeState); ok {
+		s.off = len(s.buf)
+		s.opts = EncoderOptions{}
+		return s
+	}
+	return &encodeState{}
+}
+
+func (s *encodeState) release() {
+	if cap(s.buf) <= maxPooledSize {
+		encodeStatePool.Put(s)
+	}
+}
+
+func (s *encodeState) bytes() []byte {
+	return s.buf[s.off:]
+}
+
+func (s *encodeState) len() int {
+	return len(s.buf) - s.off
+}
+
+// grow makes room for at least n more bytes in front of the encoded data.
+func (s *encodeState) grow(n int) {
+	if s.off >= n {
+		return
+	}
+	l := s.len()
+	c := 2*len(s.buf) + n
+	if c < 64 {
+		c = 64
+	}
+	buf := make([]byte, c)
+	copy(buf[c-l:], s.bytes())
+	s.buf, s.off = buf, c-l
+}
+
+func (s *encodeState) prependByte(c byte) {
+	s.grow(1)
+	s.off--
+	s.buf[s.off] = c
+}
+
+func (s *encodeState) prepend(b []byte) {
+	s.grow(len(b))
+	s.off -= len(b)
+	copy(s.buf[s.off:], b)
+}
+
+func (s *encodeState) prependString(str string) {
+	s.grow(len(str))
+	s.off -= len(str)
+	copy(s.buf[s.off:], str)
+}
+
+// prependSize writes SIZE and `:` for the payload of n bytes.
+func (s *encodeState) prependSize(n int) {
+	var a [20]byte
+	s.prependByte(':')
+	s.prepend(strconv.AppendInt(a[:0], int64(n), 10))
+}
+
+// prependTNetstring writes a whole tnetstring with the payload b and the type char t.
+func (s *encodeState) prependTNetstring(b []byte, t byte) {
+	s.prependByte(t)
+	s.prepend(b)
+	s.prependSize(len(b))
+}
+
+// open starts a dictionary or a list of type char t and returns the mark to pass to close.
+func (s *encodeState) open(t byte) int {
+	s.prependByte(t)
+	return s.len()
+}
+
+// close finishes the dictionary or the list started with open by writing its size.
+func (s *encodeState) close(mark int) {
+	s.prependSize(s.len() - mark)
+}
+
+// marshal encodes v as the top-level value. An error is returned as an *EncodeError.
+func (s *encodeState) marshal(v reflect.Value) error {
+	err := s.encode(v)
+	if err == nil {
+		return nil
+	}
+	e := encodeError(err, v, "")
+	if e.Path != "" {
+		t := v.Type()
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
 		}
-		_, err = e.Write(d)
-		if err != nil {
-			return err
+		e.Path = t.Name() + e.Path
+	}
+	return e
+}
+
+// encodeError returns err as an *EncodeError with elem prepended to the path.
+// The offending type is taken from err if it has one, otherwise it's the type of v.
+func encodeError(err error, v reflect.Value, elem string) *EncodeError {
+	e, ok := err.(*EncodeError)
+	if !ok {
+		e = &EncodeError{Err: err}
+		switch err := err.(type) {
+		case ErrUnsupportedType:
+			e.Type = err.Type
+		case ErrMarshaler:
+			e.Type = err.Type
+		default:
+			e.Type = v.Type()
 		}
-		_, err = fmt.Fprintf(e, ";")
-		return err
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		s := fmt.Sprintf("%d", val)
-		_, err := fmt.Fprintf(e, "%d:%s#", len(s), s)
-		return err
+	}
+	e.Path = elem + e.Path
+	return e
+}
+
+func (s *encodeState) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		return encodeNull(s, v)
+	}
+	return typeEncoder(v.Type())(s, v)
+}
+
+// encoderFunc writes the tnetstring of v which is of the type the function was built for.
+type encoderFunc func(s *encodeState, v reflect.Value) error
+
+var encoderCache sync.Map // map[reflect.Type]encoderFunc
+
+// typeEncoder returns the cached encoderFunc for t building it on the first call.
+func typeEncoder(t reflect.Type) encoderFunc {
+	if f, ok := encoderCache.Load(t); ok {
+		return f.(encoderFunc)
+	}
+
+	// Store an indirect function first so that recursive types can refer to themselves while being built.
+	var (
+		wg sync.WaitGroup
+		f  encoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := encoderCache.LoadOrStore(t, encoderFunc(func(s *encodeState, v reflect.Value) error {
+		wg.Wait()
+		return f(s, v)
+	}))
+	if loaded {
+		return fi.(encoderFunc)
+	}
+
+	f = newTypeEncoder(t)
+	wg.Done()
+	encoderCache.Store(t, f)
+	return f
+}
+
+func newTypeEncoder(t reflect.Type) encoderFunc {
+	if t.Kind() == reflect.Interface {
+		return encodeInterface
+	}
+	if f := newNumberEncoder(t); f != nil {
+		return f
+	}
+	if f := newTimeEncoder(t, nil); f != nil {
+		return f
+	}
+	if t.Kind() != reflect.Ptr {
+		p := reflect.PtrTo(t)
+		switch {
+		case p.Implements(marshalerType) && !t.Implements(marshalerType):
+			return addrEncoder(marshalerEncoder)
+		case p.Implements(textMarshalerType) && !t.Implements(textMarshalerType):
+			return addrEncoder(textMarshalerEncoder)
+		case p.Implements(binaryMarshalerType) && !t.Implements(binaryMarshalerType):
+			return addrEncoder(binaryMarshalerEncoder)
+		}
+	}
+	switch {
+	case t.Implements(marshalerType):
+		return marshalerEncoder
+	case t.Implements(textMarshalerType):
+		return textMarshalerEncoder
+	case t.Implements(binaryMarshalerType):
+		return binaryMarshalerEncoder
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return encodeString
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return encodeInt
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return encodeUint
 	case reflect.Float32, reflect.Float64:
-		s := fmt.Sprintf("%f", val)
-		_, err := fmt.Fprintf(e, "%d:%s^", len(s), s)
-		return err
+		return encodeFloat
 	case reflect.Bool:
-		s := strconv.FormatBool(val.(bool))
-		_, err := fmt.Fprintf(e, "%d:%s!", len(s), s)
-		return err
-	case reflect.Invalid:
-		_, err := fmt.Fprint(e, "0:~")
-		return err
+		return encodeBool
 	case reflect.Ptr:
-		v = v.Elem()
-		return e.Encode(v.Interface())
+		return newPtrEncoder(t)
 	case reflect.Map:
-		return e.encodeMap(v)
+		return newMapEncoder(t)
 	case reflect.Struct:
-		return e.encodeStruct(v)
+		return newStructEncoder(t)
 	case reflect.Array, reflect.Slice:
-		return e.encodeSlice(v)
+		return newSliceEncoder(t)
+	}
+	return encodeUnsupported
+}
+
+// addrEncoder calls f with a pointer to v so that methods with pointer receivers are found.
+func addrEncoder(f encoderFunc) encoderFunc {
+	return func(s *encodeState, v reflect.Value) error {
+		if v.CanAddr() {
+			return f(s, v.Addr())
+		}
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		return f(s, p)
+	}
+}
+
+// implements returns v as an interface of type t if either v or a pointer to v implements it.
+func implements(v reflect.Value, t reflect.Type) (interface{}, bool) {
+	if !v.IsValid() || v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, false
+	}
+	if v.Type().Implements(t) {
+		return v.Interface(), true
+	}
+	if reflect.PtrTo(v.Type()).Implements(t) {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		return p.Interface(), true
+	}
+	return nil, false
+}
+
+func marshalerEncoder(s *encodeState, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return encodeNull(s, v)
+	}
+	b, err := v.Interface().(Marshaler).MarshalTNetstring()
+	if err != nil {
+		return ErrMarshaler{Type: v.Type(), Err: err}
+	}
+	if err := validate(b); err != nil {
+		return ErrMarshaler{Type: v.Type(), Err: err}
+	}
+	s.prepend(b)
+	return nil
+}
+
+func textMarshalerEncoder(s *encodeState, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return encodeNull(s, v)
+	}
+	b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return ErrMarshaler{Type: v.Type(), Err: err}
+	}
+	s.prependTNetstring(b, s.stringChar())
+	return nil
+}
+
+func binaryMarshalerEncoder(s *encodeState, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return encodeNull(s, v)
+	}
+	b, err := v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return ErrMarshaler{Type: v.Type(), Err: err}
+	}
+	s.prependTNetstring(b, ',')
+	return nil
+}
+
+// stringChar returns the type char for strings in the Dialect.
+func (s *encodeState) stringChar() byte {
+	if s.opts.Dialect == TNetstring3 {
+		return ';'
+	}
+	return ','
+}
+
+func encodeString(s *encodeState, v reflect.Value) error {
+	str := v.String()
+	s.prependByte(s.stringChar())
+	s.prependString(str)
+	s.prependSize(len(str))
+	return nil
+}
+
+func encodeInt(s *encodeState, v reflect.Value) error {
+	var a [20]byte
+	s.prependTNetstring(strconv.AppendInt(a[:0], v.Int(), 10), '#')
+	return nil
+}
+
+func encodeUint(s *encodeState, v reflect.Value) error {
+	var a [20]byte
+	s.prependTNetstring(strconv.AppendUint(a[:0], v.Uint(), 10), '#')
+	return nil
+}
+
+func encodeFloat(s *encodeState, v reflect.Value) error {
+	f := v.Float()
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		switch s.opts.NonFinite {
+		case NonFiniteTokens:
+		case NonFiniteNull:
+			s.prependTNetstring(nil, '~')
+			return nil
+		default:
+			return ErrNonFinite
+		}
+	}
+	format, prec := byte('g'), -1
+	if s.opts.FloatFormat != 0 {
+		format, prec = s.opts.FloatFormat, s.opts.FloatPrecision
+	}
+	var a [32]byte
+	s.prependTNetstring(strconv.AppendFloat(a[:0], f, format, prec, 8*int(v.Type().Size())), '^')
+	return nil
+}
+
+func encodeBool(s *encodeState, v reflect.Value) error {
+	var a [5]byte
+	s.prependTNetstring(strconv.AppendBool(a[:0], v.Bool()), '!')
+	return nil
+}
+
+func encodeNull(s *encodeState, _ reflect.Value) error {
+	s.prependString("0:~")
+	return nil
+}
+
+func encodeInterface(s *encodeState, v reflect.Value) error {
+	if v.IsNil() {
+		return encodeNull(s, v)
 	}
+	return s.encode(v.Elem())
+}
+
+func encodeUnsupported(_ *encodeState, v reflect.Value) error {
 	return ErrUnsupportedType{Type: v.Type()}
 }
 
-func (e *Encoder) encodeMap(v reflect.Value) error {
-	var buf bytes.Buffer
-	f := NewEncoder(&buf)
-	ks := v.MapKeys()
-	sort.Slice(ks, func(i, j int) bool {
-		return ks[i].String() < ks[j].String()
-	})
-	for _, k := range ks {
-		if err := f.Encode(k.Interface()); err != nil {
-			return err
+func newPtrEncoder(t reflect.Type) encoderFunc {
+	return ptrEncoder(typeEncoder(t.Elem()))
+}
+
+// ptrEncoder returns an encoderFunc which encodes nil as null and the pointee with elem otherwise.
+func ptrEncoder(elem encoderFunc) encoderFunc {
+	return func(s *encodeState, v reflect.Value) error {
+		if v.IsNil() {
+			return encodeNull(s, v)
 		}
-		if err := f.Encode(v.MapIndex(k).Interface()); err != nil {
+		return elem(s, v.Elem())
+	}
+}
+
+type mapEntry struct {
+	name string
+	key  reflect.Value
+	val  reflect.Value
+}
+
+func newMapEncoder(t reflect.Type) encoderFunc {
+	me := newMapEntriesEncoder(t, nil)
+	return func(s *encodeState, v reflect.Value) error {
+		mark := s.open('}')
+		if err := me(s, v); err != nil {
 			return err
 		}
+		s.close(mark)
+		return nil
 	}
-	_, err := fmt.Fprintf(e, "%d:%s}", buf.Len(), buf.Bytes())
-	return err
 }
 
-func (e *Encoder) encodeStruct(v reflect.Value) error {
-	var buf bytes.Buffer
-	f := NewEncoder(&buf)
-	for i := 0; i < v.NumField(); i++ {
-		ft := v.Type().Field(i)
-		fv := v.Field(i)
-		if !fv.CanInterface() {
-			continue
+// newMapEntriesEncoder returns an encoderFunc which writes the key-value pairs of a map of type t sorted by key
+// without the surrounding size and type char. Keys in skip are left out.
+func newMapEntriesEncoder(t reflect.Type, skip map[string]*field) encoderFunc {
+	text := t.Key().Kind() != reflect.String &&
+		(t.Key().Implements(textMarshalerType) || reflect.PtrTo(t.Key()).Implements(textMarshalerType))
+	if t.Key().Kind() != reflect.String && !text {
+		return func(*encodeState, reflect.Value) error {
+			return ErrNonStringKey
 		}
-
-		tag := parseTag(ft)
-		if tag == nil {
-			continue
+	}
+	elem := typeEncoder(t.Elem())
+	return func(s *encodeState, v reflect.Value) error {
+		es := make([]mapEntry, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			me := mapEntry{name: k.String(), key: k, val: v.MapIndex(k)}
+			if text {
+				m, _ := implements(k, textMarshalerType)
+				b, err := m.(encoding.TextMarshaler).MarshalText()
+				if err != nil {
+					return encodeError(ErrMarshaler{Type: k.Type(), Err: err}, k, mapKeyElem(me.name))
+				}
+				me.name, me.key = string(b), reflect.ValueOf(string(b))
+			}
+			if _, ok := skip[me.name]; ok {
+				continue
+			}
+			es = append(es, me)
 		}
-		if tag.omitEmpty && fv == reflect.Zero(ft.Type) {
-			continue
+		sort.Slice(es, func(i, j int) bool {
+			return es[i].name < es[j].name
+		})
+		for i := len(es) - 1; i >= 0; i-- {
+			if err := elem(s, es[i].val); err != nil {
+				return encodeError(err, es[i].val, mapKeyElem(es[i].name))
+			}
+			_ = encodeString(s, es[i].key)
 		}
+		return nil
+	}
+}
 
-		if err := f.Encode(tag.displayName); err != nil {
-			return err
+func mapKeyElem(name string) string {
+	return "[" + strconv.Quote(name) + "]"
+}
+
+func newStructEncoder(t reflect.Type) encoderFunc {
+	fields := cachedTypeFields(t)
+	return func(s *encodeState, v reflect.Value) error {
+		mark := s.open('}')
+		for i := len(fields.list) - 1; i >= 0; i-- {
+			f := &fields.list[i]
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok || f.omit != nil && f.omit(fv) {
+				continue
+			}
+			if f == fields.remain {
+				if err := fields.remainEncoder(s, fv); err != nil {
+					return encodeError(err, fv, "."+t.FieldByIndex(f.index).Name)
+				}
+				continue
+			}
+
+			if err := f.encoder(s, fv); err != nil {
+				return encodeError(err, fv, "."+t.FieldByIndex(f.index).Name)
+			}
+			s.prependByte(s.stringChar())
+			s.prepend(f.key)
 		}
-		if err := f.Encode(fv.Interface()); err != nil {
-			return err
+		s.close(mark)
+		return nil
+	}
+}
+
+// isEmptyValue reports whether v is false, 0, a nil pointer or interface, or an empty string, array, slice or map.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// isZeroValue reports whether v is the zero value of its type, preferring its IsZero method if it has one.
+func isZeroValue(v reflect.Value) bool {
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return true
+	}
+	if z, ok := implements(v, isZeroerType); ok {
+		return z.(isZeroer).IsZero()
+	}
+	return v.IsZero()
+}
+
+// omitFunc returns the function which reports whether a field of type t is omitted according to its tag.
+func omitFunc(tag *tag, t reflect.Type) func(reflect.Value) bool {
+	isZero := reflect.Value.IsZero
+	if t.Implements(isZeroerType) || reflect.PtrTo(t).Implements(isZeroerType) {
+		isZero = isZeroValue
+	}
+	switch {
+	case tag.omitEmpty && tag.omitZero:
+		return func(v reflect.Value) bool {
+			return isEmptyValue(v) || isZero(v)
 		}
+	case tag.omitEmpty:
+		return isEmptyValue
+	case tag.omitZero:
+		return isZero
 	}
-	_, err := fmt.Fprintf(e, "%d:%s}", buf.Len(), buf.Bytes())
-	return err
+	return nil
 }
 
-func (e *Encoder) encodeSlice(v reflect.Value) error {
-	if v.Type().Elem().Kind() == reflect.Uint8 {
-		s := fmt.Sprintf("%s", v.Interface())
-		_, err := fmt.Fprintf(e, "%d:%s,", len(s), s)
-		return err
+func newSliceEncoder(t reflect.Type) encoderFunc {
+	if t.Elem().Kind() == reflect.Uint8 {
+		return encodeByteSlice
 	}
-	var buf bytes.Buffer
-	f := NewEncoder(&buf)
-	for i := 0; i < v.Len(); i++ {
-		if err := f.Encode(v.Index(i).Interface()); err != nil {
-			return err
+	elem := typeEncoder(t.Elem())
+	return func(s *encodeState, v reflect.Value) error {
+		mark := s.open(']')
+		for i := v.Len() - 1; i >= 0; i-- {
+			if err := elem(s, v.Index(i)); err != nil {
+				return encodeError(err, v.Index(i), "["+strconv.Itoa(i)+"]")
+			}
 		}
+		s.close(mark)
+		return nil
 	}
-	_, err := fmt.Fprintf(e, "%d:%s]", buf.Len(), buf.Bytes())
-	return err
+}
+
+func encodeByteSlice(s *encodeState, v reflect.Value) error {
+	if v.Kind() == reflect.Slice {
+		s.prependTNetstring(v.Bytes(), ',')
+		return nil
+	}
+	s.prependByte(',')
+	for i := v.Len() - 1; i >= 0; i-- {
+		s.prependByte(byte(v.Index(i).Uint()))
+	}
+	s.prependSize(v.Len())
+	return nil
 }
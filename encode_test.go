@@ -2,7 +2,14 @@ package tnetstrings
 
 import (
 	"bytes"
+	"errors"
+	"math"
+	"math/big"
+	"net"
+	"net/url"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -48,7 +55,7 @@ func TestEncoder_Encode(t *testing.T) {
 		{
 			title: "positive float",
 			in:    1.0,
-			out:   "8:1.000000^",
+			out:   "1:1^",
 		},
 		{
 			title: "nil",
@@ -186,14 +193,14 @@ func TestEncoder_Encode(t *testing.T) {
 	for _, tc := range testCases {
 		var buf bytes.Buffer
 		e := NewEncoder(&buf)
-		if err := e.Encode(tc.in); err != tc.err {
+		if err := e.Encode(tc.in); !isError(err, tc.err) {
 			t.Error(err)
 		}
 		if tc.out != buf.String() {
 			t.Errorf("[%s] expected: %s, got: %s", tc.title, tc.out, buf.String())
 		}
 		buf.Reset()
-		if err := e.Encode(&tc.in); err != tc.err {
+		if err := e.Encode(&tc.in); !isError(err, tc.err) {
 			t.Error(err)
 		}
 		if tc.out != buf.String() {
@@ -201,3 +208,616 @@ func TestEncoder_Encode(t *testing.T) {
 		}
 	}
 }
+
+type testID int
+
+func (i testID) MarshalTNetstring() ([]byte, error) {
+	s := "id-" + strconv.Itoa(int(i))
+	return []byte(strconv.Itoa(len(s)) + ":" + s + ","), nil
+}
+
+func (i *testID) UnmarshalTNetstring(b []byte) error {
+	var s string
+	if err := Unmarshal(b, &s); err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(s, "id-"))
+	*i = testID(n)
+	return err
+}
+
+type testPoint struct {
+	X, Y int
+}
+
+func (p *testPoint) MarshalTNetstring() ([]byte, error) {
+	return Marshal([]int{p.X, p.Y})
+}
+
+func (p *testPoint) UnmarshalTNetstring(b []byte) error {
+	var a [2]int
+	if err := Unmarshal(b, &a); err != nil {
+		return err
+	}
+	p.X, p.Y = a[0], a[1]
+	return nil
+}
+
+type testBadMarshaler struct{}
+
+func (testBadMarshaler) MarshalTNetstring() ([]byte, error) {
+	return []byte("3:abc,garbage"), nil
+}
+
+func TestEncoder_Encode_marshaler(t *testing.T) {
+	testCases := []struct {
+		title string
+		in    interface{}
+		out   string
+		err   error
+	}{
+		{
+			title: "value receiver",
+			in:    testID(7),
+			out:   "4:id-7,",
+		},
+		{
+			title: "pointer receiver",
+			in:    &testPoint{X: 1, Y: 2},
+			out:   "8:1:1#1:2#]",
+		},
+		{
+			title: "pointer receiver on value",
+			in:    testPoint{X: 1, Y: 2},
+			out:   "8:1:1#1:2#]",
+		},
+		{
+			title: "nil pointer",
+			in:    (*testPoint)(nil),
+			out:   "0:~",
+		},
+		{
+			title: "slice elements",
+			in:    []testID{1, 2},
+			out:   "14:4:id-1,4:id-2,]",
+		},
+		{
+			title: "nested in slice",
+			in:    []testPoint{{X: 1, Y: 2}},
+			out:   "11:8:1:1#1:2#]]",
+		},
+		{
+			title: "malformed output",
+			in:    testBadMarshaler{},
+			err:   ErrMarshaler{Type: reflect.TypeOf(testBadMarshaler{}), Err: ErrTrailingData},
+		},
+	}
+
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(tc.in); !isError(err, tc.err) {
+			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
+		}
+		if tc.out != buf.String() {
+			t.Errorf("[%s] expected: %s, got: %s", tc.title, tc.out, buf.String())
+		}
+	}
+}
+
+type testKey struct {
+	a, b string
+}
+
+func (k testKey) MarshalText() ([]byte, error) {
+	return []byte(k.a + "/" + k.b), nil
+}
+
+func (k *testKey) UnmarshalText(b []byte) error {
+	ss := strings.SplitN(string(b), "/", 2)
+	if len(ss) != 2 {
+		return errors.New("invalid key")
+	}
+	k.a, k.b = ss[0], ss[1]
+	return nil
+}
+
+func TestEncoder_Encode_textMarshaler(t *testing.T) {
+	testCases := []struct {
+		title string
+		in    interface{}
+		out   string
+	}{
+		{
+			title: "net.IP",
+			in:    net.IPv4(127, 0, 0, 1),
+			out:   "9:127.0.0.1,",
+		},
+		{
+			title: "big.Int",
+			in:    big.NewInt(1234567890),
+			out:   "10:1234567890#",
+		},
+		{
+			title: "binary marshaler",
+			in:    url.URL{Scheme: "http", Host: "example.com"},
+			out:   "18:http://example.com,",
+		},
+		{
+			title: "text marshaler key",
+			in:    map[testKey]int{{a: "foo", b: "bar"}: 1},
+			out:   "14:7:foo/bar,1:1#}",
+		},
+		{
+			title: "sorted text marshaler keys",
+			in:    map[testKey]int{{a: "b"}: 2, {a: "a"}: 1},
+			out:   "18:2:a/,1:1#2:b/,1:2#}",
+		},
+	}
+
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(tc.in); err != nil {
+			t.Errorf("[%s] %v", tc.title, err)
+		}
+		if tc.out != buf.String() {
+			t.Errorf("[%s] expected: %s, got: %s", tc.title, tc.out, buf.String())
+		}
+	}
+}
+
+type testHeader struct {
+	ID   int
+	Kind string
+}
+
+// Trace is exported so that it can be allocated when embedded as a pointer.
+type Trace struct {
+	Trace string
+}
+
+type testMeta struct {
+	Source string
+}
+
+type testMessage struct {
+	testHeader
+	*Trace
+	Body string
+	Meta testMeta `tnetstrings:",inline"`
+}
+
+type testNamed struct {
+	Name string
+	X    int
+}
+
+type testOtherNamed struct {
+	Name string
+	Y    int
+}
+
+type testTaggedNamed struct {
+	Name string `tnetstrings:"Name"`
+}
+
+func TestEncoder_Encode_embedded(t *testing.T) {
+	testCases := []struct {
+		title string
+		in    interface{}
+		out   map[string]interface{}
+	}{
+		{
+			title: "embedded structs",
+			in: testMessage{
+				testHeader: testHeader{ID: 1, Kind: "kind"},
+				Trace:      &Trace{Trace: "trace"},
+				Body:       "body",
+				Meta:       testMeta{Source: "source"},
+			},
+			out: map[string]interface{}{
+				"ID":     int64(1),
+				"Kind":   "kind",
+				"Trace":  "trace",
+				"Body":   "body",
+				"Source": "source",
+			},
+		},
+		{
+			title: "nil embedded pointer",
+			in:    testMessage{Body: "body"},
+			out: map[string]interface{}{
+				"ID":     int64(0),
+				"Kind":   "",
+				"Body":   "body",
+				"Source": "",
+			},
+		},
+		{
+			title: "conflicting fields",
+			in: struct {
+				testNamed
+				testOtherNamed
+			}{
+				testNamed:      testNamed{Name: "a", X: 1},
+				testOtherNamed: testOtherNamed{Name: "b", Y: 2},
+			},
+			out: map[string]interface{}{
+				"X": int64(1),
+				"Y": int64(2),
+			},
+		},
+		{
+			title: "shallower field",
+			in: struct {
+				testNamed
+				Name string
+			}{
+				testNamed: testNamed{Name: "a", X: 1},
+				Name:      "b",
+			},
+			out: map[string]interface{}{
+				"Name": "b",
+				"X":    int64(1),
+			},
+		},
+		{
+			title: "tagged field",
+			in: struct {
+				testNamed
+				testTaggedNamed
+			}{
+				testNamed:       testNamed{Name: "a", X: 1},
+				testTaggedNamed: testTaggedNamed{Name: "b"},
+			},
+			out: map[string]interface{}{
+				"Name": "b",
+				"X":    int64(1),
+			},
+		},
+		{
+			title: "named embedded struct",
+			in: struct {
+				Trace `tnetstrings:"named"`
+			}{
+				Trace: Trace{Trace: "trace"},
+			},
+			out: map[string]interface{}{
+				"named": map[string]interface{}{
+					"Trace": "trace",
+				},
+			},
+		},
+		{
+			title: "named unexported embedded struct",
+			in: struct {
+				testNamed `tnetstrings:"named"`
+			}{
+				testNamed: testNamed{Name: "a", X: 1},
+			},
+			out: map[string]interface{}{},
+		},
+	}
+
+	for _, tc := range testCases {
+		b, err := Marshal(tc.in)
+		if err != nil {
+			t.Errorf("[%s] %v", tc.title, err)
+		}
+		var m map[string]interface{}
+		if err := Unmarshal(b, &m); err != nil {
+			t.Errorf("[%s] %v", tc.title, err)
+		}
+		if !reflect.DeepEqual(tc.out, m) {
+			t.Errorf("[%s] expected: %#v, got: %#v", tc.title, tc.out, m)
+		}
+	}
+}
+
+type testZeroer struct {
+	Value int
+}
+
+func (z testZeroer) IsZero() bool {
+	return z.Value < 0
+}
+
+func TestEncoder_Encode_omit(t *testing.T) {
+	type omitEmpty struct {
+		String    string                 `tnetstrings:",omitempty"`
+		Int       int                    `tnetstrings:",omitempty"`
+		Uint      uint                   `tnetstrings:",omitempty"`
+		Float     float64                `tnetstrings:",omitempty"`
+		Bool      bool                   `tnetstrings:",omitempty"`
+		Ptr       *int                   `tnetstrings:",omitempty"`
+		Interface interface{}            `tnetstrings:",omitempty"`
+		Map       map[string]interface{} `tnetstrings:",omitempty"`
+		Slice     []int                  `tnetstrings:",omitempty"`
+		Array     [0]int                 `tnetstrings:",omitempty"`
+		Struct    struct{}               `tnetstrings:",omitempty"`
+	}
+	type omitZero struct {
+		Int    int             `tnetstrings:",omitzero"`
+		Slice  []int           `tnetstrings:",omitzero"`
+		Array  [1]int          `tnetstrings:",omitzero"`
+		Struct struct{ X int } `tnetstrings:",omitzero"`
+		Zeroer testZeroer      `tnetstrings:",omitzero"`
+		Ptr    *testZeroer     `tnetstrings:",omitzero"`
+		Map    map[string]int  `tnetstrings:",omitzero"`
+	}
+
+	testCases := []struct {
+		title string
+		in    interface{}
+		out   map[string]interface{}
+	}{
+		{
+			title: "omitempty with empty values",
+			in:    omitEmpty{Map: map[string]interface{}{}, Slice: []int{}},
+			out:   map[string]interface{}{"Struct": map[string]interface{}{}},
+		},
+		{
+			title: "omitempty with non-empty values",
+			in: omitEmpty{
+				String:    "a",
+				Int:       -1,
+				Uint:      1,
+				Float:     0.5,
+				Bool:      true,
+				Ptr:       new(int),
+				Interface: 0,
+				Map:       map[string]interface{}{"a": nil},
+				Slice:     []int{0},
+			},
+			out: map[string]interface{}{
+				"String":    "a",
+				"Int":       int64(-1),
+				"Uint":      int64(1),
+				"Float":     0.5,
+				"Bool":      true,
+				"Ptr":       int64(0),
+				"Interface": int64(0),
+				"Map":       map[string]interface{}{"a": nil},
+				"Slice":     []interface{}{int64(0)},
+				"Struct":    map[string]interface{}{},
+			},
+		},
+		{
+			title: "omitzero with zero values",
+			in:    omitZero{Zeroer: testZeroer{Value: -1}, Ptr: &testZeroer{Value: -1}},
+			out:   map[string]interface{}{},
+		},
+		{
+			title: "omitzero with non-zero values",
+			in: omitZero{
+				Slice:  []int{},
+				Array:  [1]int{1},
+				Struct: struct{ X int }{X: 1},
+				Zeroer: testZeroer{Value: 0},
+				Ptr:    &testZeroer{Value: 1},
+				Map:    map[string]int{},
+			},
+			out: map[string]interface{}{
+				"Slice":  []interface{}{},
+				"Array":  []interface{}{int64(1)},
+				"Struct": map[string]interface{}{"X": int64(1)},
+				"Zeroer": map[string]interface{}{"Value": int64(0)},
+				"Ptr":    map[string]interface{}{"Value": int64(1)},
+				"Map":    map[string]interface{}{},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		b, err := Marshal(tc.in)
+		if err != nil {
+			t.Errorf("[%s] %v", tc.title, err)
+		}
+		var m map[string]interface{}
+		if err := Unmarshal(b, &m); err != nil {
+			t.Errorf("[%s] %v", tc.title, err)
+		}
+		if !reflect.DeepEqual(tc.out, m) {
+			t.Errorf("[%s] expected: %#v, got: %#v", tc.title, tc.out, m)
+		}
+	}
+}
+
+type testOrder struct {
+	Items []testOrderItem
+	Meta  map[string]interface{}
+	Index map[int]string `tnetstrings:",omitempty"`
+}
+
+type testOrderItem struct {
+	Name     string
+	Callback func()
+}
+
+func TestEncoder_Encode_errors(t *testing.T) {
+	testCases := []struct {
+		title string
+		in    interface{}
+		err   *EncodeError
+	}{
+		{
+			title: "struct field in slice",
+			in:    &testOrder{Items: []testOrderItem{{Name: "foo"}, {Name: "bar", Callback: func() {}}}},
+			err:   &EncodeError{Path: "testOrder.Items[1].Callback", Type: reflect.TypeOf(func() {}), Err: ErrUnsupportedType{Type: reflect.TypeOf(func() {})}},
+		},
+		{
+			title: "map value",
+			in:    testOrder{Meta: map[string]interface{}{"foo": 1, "bar": make(chan int)}},
+			err:   &EncodeError{Path: `testOrder.Meta["bar"]`, Type: reflect.TypeOf(make(chan int)), Err: ErrUnsupportedType{Type: reflect.TypeOf(make(chan int))}},
+		},
+		{
+			title: "non string key",
+			in:    testOrder{Index: map[int]string{1: "foo"}},
+			err:   &EncodeError{Path: "testOrder.Index", Type: reflect.TypeOf(map[int]string{}), Err: ErrNonStringKey},
+		},
+		{
+			title: "top-level",
+			in:    map[int]string{},
+			err:   &EncodeError{Type: reflect.TypeOf(map[int]string{}), Err: ErrNonStringKey},
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := Marshal(tc.in)
+		var e *EncodeError
+		if !errors.As(err, &e) {
+			t.Fatalf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
+		}
+		if e.Path != tc.err.Path || e.Type != tc.err.Type || e.Err != tc.err.Err {
+			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
+		}
+	}
+}
+
+func TestEncoderOptions_Dialect(t *testing.T) {
+	type message struct {
+		Text string
+		Data []byte
+		Key  testKey
+	}
+	in := message{Text: "hi", Data: []byte("hi"), Key: testKey{a: "a", b: "b"}}
+
+	tests := []struct {
+		dialect Dialect
+		out     string
+	}{
+		{Classic, "36:4:Text,2:hi,4:Data,2:hi,3:Key,3:a/b,}"},
+		{TNetstring3, "36:4:Text;2:hi;4:Data;2:hi,3:Key;3:a/b;}"},
+	}
+	for _, test := range tests {
+		b, err := EncoderOptions{Dialect: test.dialect}.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.out {
+			t.Errorf("%v: expected: %s, got: %s", test.dialect, test.out, b)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := (EncoderOptions{Dialect: TNetstring3}).NewEncoder(&buf).Encode(map[string]string{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "8:1:k;1:v;}"; buf.String() != expected {
+		t.Errorf("expected: %s, got: %s", expected, buf.String())
+	}
+}
+
+func TestEncoderOptions_float(t *testing.T) {
+	tests := []struct {
+		opts EncoderOptions
+		in   interface{}
+		out  string
+		err  error
+	}{
+		{EncoderOptions{}, 1e-9, "5:1e-09^", nil},
+		{EncoderOptions{}, 1e300, "6:1e+300^", nil},
+		{EncoderOptions{}, 0.1, "3:0.1^", nil},
+		{EncoderOptions{}, float32(0.1), "3:0.1^", nil},
+		{EncoderOptions{FloatFormat: 'f', FloatPrecision: 2}, 3.14159, "4:3.14^", nil},
+		{EncoderOptions{FloatFormat: 'e', FloatPrecision: -1}, 1500.0, "7:1.5e+03^", nil},
+		{EncoderOptions{}, math.NaN(), "", ErrNonFinite},
+		{EncoderOptions{NonFinite: NonFiniteTokens}, math.NaN(), "3:NaN^", nil},
+		{EncoderOptions{NonFinite: NonFiniteTokens}, math.Inf(1), "4:+Inf^", nil},
+		{EncoderOptions{NonFinite: NonFiniteTokens}, math.Inf(-1), "4:-Inf^", nil},
+		{EncoderOptions{NonFinite: NonFiniteNull}, math.Inf(1), "0:~", nil},
+	}
+	for _, test := range tests {
+		out, err := test.opts.Marshal(test.in)
+		if !isError(err, test.err) {
+			t.Errorf("%+v %v: expected: %v, got: %v", test.opts, test.in, test.err, err)
+		}
+		if string(out) != test.out {
+			t.Errorf("%+v %v: expected: %s, got: %s", test.opts, test.in, test.out, out)
+		}
+	}
+
+	for _, f := range []float64{1e-9, 1e300, 0.1, math.MaxFloat64, math.SmallestNonzeroFloat64, -123.456} {
+		b, err := Marshal(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var g float64
+		if err := Unmarshal(b, &g); err != nil || g != f {
+			t.Errorf("expected: %v, got: %v, %v", f, g, err)
+		}
+	}
+
+	var f float64
+	if err := Unmarshal([]byte("3:NaN^"), &f); !errors.Is(err, ErrNonFinite) {
+		t.Errorf("expected: %v, got: %v", ErrNonFinite, err)
+	}
+	if err := (DecoderOptions{NonFinite: NonFiniteTokens}).Unmarshal([]byte("4:-Inf^"), &f); err != nil || !math.IsInf(f, -1) {
+		t.Errorf("expected: -Inf, got: %v, %v", f, err)
+	}
+}
+
+type benchmarkItem struct {
+	ID    int64   `tnetstrings:"id"`
+	Name  string  `tnetstrings:"name"`
+	Price float64 `tnetstrings:"price"`
+	Tags  []string
+}
+
+type benchmarkOrder struct {
+	testHeader
+	Customer string          `tnetstrings:"customer"`
+	Note     string          `tnetstrings:"note,omitempty"`
+	Items    []benchmarkItem `tnetstrings:"items"`
+	Paid     bool            `tnetstrings:"paid"`
+}
+
+var benchmarkOrderValue = benchmarkOrder{
+	testHeader: testHeader{ID: 1, Kind: "order"},
+	Customer:   "customer",
+	Items: []benchmarkItem{
+		{ID: 1, Name: "foo", Price: 1.5, Tags: []string{"a", "b"}},
+		{ID: 2, Name: "bar", Price: 2.5, Tags: []string{"c"}},
+		{ID: 3, Name: "baz", Price: 3.5},
+	},
+	Paid: true,
+}
+
+func BenchmarkEncoder_Encode_struct(b *testing.B) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := e.Encode(&benchmarkOrderValue); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkEncode(b *testing.B, val interface{}) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := e.Encode(val); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncoder_Encode_deep(b *testing.B) {
+	var val interface{} = "leaf"
+	for i := 0; i < 100; i++ {
+		val = []interface{}{i, val}
+	}
+	benchmarkEncode(b, val)
+}
+
+func BenchmarkEncoder_Encode_wide(b *testing.B) {
+	val := make(map[string][]benchmarkItem, 100)
+	for i := 0; i < 100; i++ {
+		val[strconv.Itoa(i)] = benchmarkOrderValue.Items
+	}
+	benchmarkEncode(b, val)
+}
@@ -1,9 +1,11 @@
 package tnetstrings
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 )
 
 // ErrUnsupportedType means the argument type is not eligible to encode/decode.
@@ -15,7 +17,25 @@ func (e ErrUnsupportedType) Error() string {
 	return fmt.Sprintf("unsupported type: %s", e.Type)
 }
 
-// ErrNonStringKey means passed map has non-string key which is not accepted in tnetstrings.
+// ErrInvalidUnmarshal means the argument of Unmarshal or Decode isn't a non-nil pointer.
+// Type is nil if the argument is nil.
+type ErrInvalidUnmarshal struct {
+	reflect.Type
+}
+
+func (e ErrInvalidUnmarshal) Error() string {
+	switch {
+	case e.Type == nil:
+		return "unmarshal into nil"
+	case e.Kind() != reflect.Ptr:
+		return fmt.Sprintf("unmarshal into non-pointer %s", e.Type)
+	default:
+		return fmt.Sprintf("unmarshal into nil %s", e.Type)
+	}
+}
+
+// ErrNonStringKey means a map key is neither a string nor an encoding.TextMarshaler, or a dictionary key
+// in the input isn't a string. Neither is accepted in tnetstrings.
 var ErrNonStringKey = errors.New("non string key")
 
 // ErrSizeLimitExceeded means SIZE is longer than 9 digits.
@@ -34,3 +54,198 @@ type ErrInvalidTypeChar uint8
 func (e ErrInvalidTypeChar) Error() string {
 	return fmt.Sprintf("invalid type char: %s", string(e))
 }
+
+// ErrNonFinite means a float is NaN or ±Inf, which the NonFinitePolicy doesn't allow.
+var ErrNonFinite = errors.New("non-finite float")
+
+// ErrInexactDecimal means a big.Rat has no finite decimal representation, such as 1/3.
+var ErrInexactDecimal = errors.New("no exact decimal representation")
+
+// ErrTrailingData means there are extra bytes after the top-level tnetstring.
+var ErrTrailingData = errors.New("trailing data")
+
+// ErrMarshaler means a Marshaler, encoding.TextMarshaler or encoding.BinaryMarshaler failed,
+// or a Marshaler returned something other than a single well-formed tnetstring.
+type ErrMarshaler struct {
+	reflect.Type
+	Err error
+}
+
+func (e ErrMarshaler) Error() string {
+	return fmt.Sprintf("error marshaling type %s: %v", e.Type, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e ErrMarshaler) Unwrap() error {
+	return e.Err
+}
+
+// ErrUnknownField means the input has a dictionary key at Offset which doesn't match any struct field.
+type ErrUnknownField struct {
+	Key    string
+	Offset int64
+}
+
+func (e ErrUnknownField) Error() string {
+	return fmt.Sprintf("unknown field %q at offset %d", e.Key, e.Offset)
+}
+
+// ErrNotFound means a path doesn't lead to any value.
+var ErrNotFound = errors.New("not found")
+
+// ErrInvalidPath means a query path is malformed.
+type ErrInvalidPath string
+
+func (e ErrInvalidPath) Error() string {
+	return fmt.Sprintf("invalid path: %q", string(e))
+}
+
+// ErrInvalidOp means an Operation has an unknown Op.
+type ErrInvalidOp string
+
+func (e ErrInvalidOp) Error() string {
+	return fmt.Sprintf("invalid op: %q", string(e))
+}
+
+// ErrTestFailed means a test operation of a Patch found a different value.
+var ErrTestFailed = errors.New("test failed")
+
+// ErrTypeMismatch means a value has a type char which the accessor or the path doesn't accept.
+type ErrTypeMismatch uint8
+
+func (e ErrTypeMismatch) Error() string {
+	return fmt.Sprintf("type mismatch: %s", string(e))
+}
+
+// SyntaxError means the input isn't well-formed tnetstrings.
+// Err is one of ErrInvalidSizeChar, ErrSizeLimitExceeded, ErrInvalidTypeChar, ErrNonStringKey, ErrTrailingData
+// and io.ErrUnexpectedEOF, or in strict mode one of ErrNonCanonicalSize, ErrInvalidInteger, ErrInvalidBoolean,
+// ErrNonEmptyNull and ErrInvalidUTF8.
+type SyntaxError struct {
+	Offset int64  // where the problem is found
+	Path   string // the dictionary keys and list indices leading to the value in the syntax of Query
+	Err    error
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("syntax error at offset %d: %v", e.Offset, e.Err)
+	}
+	return fmt.Sprintf("syntax error at %s (offset %d): %v", e.Path, e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError means a well-formed tnetstring can't be decoded into the destination.
+type DecodeError struct {
+	Offset   int64        // where the payload starts
+	Path     string       // the dictionary keys and list indices leading to the value in the syntax of Query
+	Type     reflect.Type // the destination type
+	Actual   byte         // the type char
+	Expected string       // the type chars the destination accepts
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	at := fmt.Sprintf("offset %d", e.Offset)
+	if e.Path != "" {
+		at = fmt.Sprintf("%s (offset %d)", e.Path, e.Offset)
+	}
+	return fmt.Sprintf("cannot decode %s into %s at %s: %v", string(e.Actual), e.Type, at, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Errors for the limits of DecoderOptions. They're wrapped in a *LimitError.
+var (
+	ErrMaxValueSize      = errors.New("max value size exceeded")
+	ErrMaxDepth          = errors.New("max depth exceeded")
+	ErrMaxTotalBytes     = errors.New("max total bytes exceeded")
+	ErrMaxContainerItems = errors.New("max container items exceeded")
+)
+
+// LimitError means the input exceeds a limit of DecoderOptions.
+// Err is one of ErrMaxValueSize, ErrMaxDepth, ErrMaxTotalBytes and ErrMaxContainerItems.
+type LimitError struct {
+	Offset int64  // where the offending value starts
+	Path   string // the dictionary keys and list indices leading to the value in the syntax of Query
+	Err    error
+}
+
+func (e *LimitError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("limit exceeded at offset %d: %v", e.Offset, e.Err)
+	}
+	return fmt.Sprintf("limit exceeded at %s (offset %d): %v", e.Path, e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// Errors for the violations of the spec found by DecoderOptions.Strict. They're wrapped in a *SyntaxError.
+var (
+	ErrNonCanonicalSize = errors.New("size with leading zeros")
+	ErrInvalidInteger   = errors.New("integer is not decimal")
+	ErrInvalidBoolean   = errors.New("boolean is neither true nor false")
+	ErrNonEmptyNull     = errors.New("null with a payload")
+	ErrInvalidUTF8      = errors.New("text string is not valid UTF-8")
+)
+
+// withPath prepends a path element to the path of a *SyntaxError, a *DecodeError or a *LimitError.
+func withPath(err error, elem string) error {
+	switch e := err.(type) {
+	case *SyntaxError:
+		e.Path = joinPath(elem, e.Path)
+	case *DecodeError:
+		e.Path = joinPath(elem, e.Path)
+	case *LimitError:
+		e.Path = joinPath(elem, e.Path)
+	}
+	return err
+}
+
+func joinPath(elem, path string) string {
+	if path == "" || path[0] == '[' {
+		return elem + path
+	}
+	return elem + "." + path
+}
+
+// keyElem returns a dictionary key as a path element which is bracketed and quoted if needed.
+func keyElem(key []byte) string {
+	if len(key) == 0 || bytes.ContainsAny(key, `.[]"*`) {
+		return "[" + strconv.Quote(string(key)) + "]"
+	}
+	return string(key)
+}
+
+func indexElem(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+// EncodeError means a value can't be encoded.
+type EncodeError struct {
+	Path string       // the Go expression of the value like `Order.Items[3].Callback`
+	Type reflect.Type // the offending type
+	Err  error
+}
+
+func (e *EncodeError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("cannot encode %s: %v", e.Type, e.Err)
+	}
+	return fmt.Sprintf("cannot encode %s at %s: %v", e.Type, e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *EncodeError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,217 @@
+package tnetstrings
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// field is a struct field which is either declared directly or promoted from an embedded struct.
+type field struct {
+	*tag
+	index []int
+	typ   reflect.Type
+
+	key     []byte // display name encoded as a tnetstring without the type char
+	omit    func(reflect.Value) bool
+	encoder encoderFunc
+	decoder decoderFunc
+}
+
+// structFields is the precomputed dictionary representation of a struct type.
+type structFields struct {
+	list   []field
+	byName map[string]*field
+	remain *field
+
+	remainEncoder encoderFunc
+}
+
+var fieldCache sync.Map // map[reflect.Type]*structFields
+
+// cachedTypeFields is like typeFields but caches the result along with the encoders and decoders of the fields.
+func cachedTypeFields(t reflect.Type) *structFields {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.(*structFields)
+	}
+	f, _ := fieldCache.LoadOrStore(t, newStructFields(t))
+	return f.(*structFields)
+}
+
+func newStructFields(t reflect.Type) *structFields {
+	fs := structFields{list: typeFields(t)}
+	fs.byName = make(map[string]*field, len(fs.list))
+	for i := range fs.list {
+		f := &fs.list[i]
+		var e encodeState
+		_ = encodeString(&e, reflect.ValueOf(f.displayName))
+		f.key = e.bytes()[:e.len()-1]
+		f.omit = omitFunc(f.tag, f.typ)
+		f.encoder = typeEncoder(f.typ)
+		f.decoder = typeDecoder(f.typ)
+		if f.hasTimeLayout {
+			if enc := newTimeEncoder(f.typ, &f.timeLayout); enc != nil {
+				f.encoder = enc
+				f.decoder = newTimeDecoder(f.typ, &f.timeLayout)
+			}
+		}
+		if f.remain && f.typ.Kind() == reflect.Map && fs.remain == nil {
+			fs.remain = f
+			continue
+		}
+		fs.byName[f.displayName] = f
+	}
+	if fs.remain != nil {
+		fs.remainEncoder = newMapEntriesEncoder(fs.remain.typ, fs.byName)
+	}
+	return &fs
+}
+
+// typeFields returns the fields which make up the dictionary representation of the struct type t.
+// Fields of embedded structs and of fields tagged with `inline` are promoted following the rules of encoding/json:
+// the shallowest field wins, a tagged field wins over an untagged one at the same depth, and otherwise
+// conflicting fields are dropped.
+func typeFields(t reflect.Type) []field {
+	var fields []field
+
+	current := []field{}
+	next := []field{{typ: t}}
+	count := map[reflect.Type]int{}
+	nextCount := map[reflect.Type]int{}
+	visited := map[reflect.Type]bool{}
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if sf.Anonymous {
+					if sf.PkgPath != "" && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if sf.PkgPath != "" {
+					continue
+				}
+
+				tag := parseTag(sf)
+				if tag == nil {
+					continue
+				}
+
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+
+				if ft.Kind() != reflect.Struct || !tag.inline && (!sf.Anonymous || tag.named) {
+					if sf.PkgPath != "" {
+						continue
+					}
+					fields = append(fields, field{tag: tag, index: index, typ: sf.Type})
+					if count[f.typ] > 1 {
+						// The same struct is embedded more than once at this depth so its fields annihilate each other.
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, field{tag: tag, index: index, typ: ft})
+				}
+			}
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		x, y := fields[i], fields[j]
+		if x.displayName != y.displayName {
+			return x.displayName < y.displayName
+		}
+		if len(x.index) != len(y.index) {
+			return len(x.index) < len(y.index)
+		}
+		if x.named != y.named {
+			return x.named
+		}
+		return byIndex(fields).Less(i, j)
+	})
+
+	out := fields[:0]
+	for i, n := 0, 0; i < len(fields); i += n {
+		name := fields[i].displayName
+		for n = 1; i+n < len(fields) && fields[i+n].displayName == name; n++ {
+		}
+		if f, ok := dominantField(fields[i : i+n]); ok {
+			out = append(out, f)
+		}
+	}
+
+	sort.Sort(byIndex(out))
+	return out
+}
+
+// dominantField returns the field which wins among fields sharing the same name sorted by depth and tag.
+func dominantField(fields []field) (field, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].named == fields[1].named {
+		return field{}, false
+	}
+	return fields[0], true
+}
+
+type byIndex []field
+
+func (x byIndex) Len() int      { return len(x) }
+func (x byIndex) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+func (x byIndex) Less(i, j int) bool {
+	for k, xik := range x[i].index {
+		if k >= len(x[j].index) {
+			return false
+		}
+		if xik != x[j].index[k] {
+			return xik < x[j].index[k]
+		}
+	}
+	return len(x[i].index) < len(x[j].index)
+}
+
+// fieldByIndex returns the field of v at index or false if it is behind a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// fieldByIndexAlloc returns the field of v at index allocating nil embedded pointers on the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, ErrUnsupportedType{Type: v.Type()}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
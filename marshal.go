@@ -0,0 +1,114 @@
+package tnetstrings
+
+import (
+	"io"
+)
+
+// Marshal returns the tnetstring encoding of val.
+func Marshal(val interface{}) ([]byte, error) {
+	return AppendMarshal(nil, val)
+}
+
+// AppendMarshal appends the tnetstring encoding of val to dst and returns the extended buffer.
+func AppendMarshal(dst []byte, val interface{}) ([]byte, error) {
+	return EncoderOptions{}.AppendMarshal(dst, val)
+}
+
+// Unmarshal decodes exactly one tnetstring from data into val.
+func Unmarshal(data []byte, val interface{}) error {
+	return DecoderOptions{}.Unmarshal(data, val)
+}
+
+// split cuts the first tnetstring off data and returns its payload, type char and the remaining bytes.
+func split(data []byte) ([]byte, byte, []byte, error) {
+	payload, t, rest, _, err := scan(data)
+	return payload, t, rest, err
+}
+
+// splitTop cuts exactly one tnetstring out of data and returns its type char, payload and the offset of the payload.
+// Errors are reported as a *SyntaxError.
+func splitTop(data []byte) (byte, []byte, int64, error) {
+	payload, t, rest, pos, err := scan(data)
+	if err != nil {
+		return 0, nil, 0, &SyntaxError{Offset: int64(pos), Err: err}
+	}
+	if len(rest) != 0 {
+		return 0, nil, 0, &SyntaxError{Offset: int64(len(data) - len(rest)), Err: ErrTrailingData}
+	}
+	return t, payload, int64(len(data) - len(rest) - len(payload) - 1), nil
+}
+
+// scan is split which also returns the position in data where an error is found.
+func scan(data []byte) ([]byte, byte, []byte, int, error) {
+	size, n, err := parseSize(data)
+	if err == io.ErrUnexpectedEOF {
+		return nil, 0, nil, n, err
+	}
+	if err != nil {
+		return nil, 0, nil, n - 1, err
+	}
+	if uint64(len(data)-n) <= size {
+		return nil, 0, nil, len(data), io.ErrUnexpectedEOF
+	}
+	payload, rest := data[n:n+int(size)], data[n+int(size)+1:]
+	switch t := data[n+int(size)]; t {
+	case ',', ';', '#', '^', '!', '~', '}', ']':
+		return payload, t, rest, 0, nil
+	default:
+		return nil, 0, nil, n + int(size), ErrInvalidTypeChar(t)
+	}
+}
+
+// parseSize is readSize for a byte slice.
+func parseSize(data []byte) (uint64, int, error) {
+	var size uint64
+	for i := 0; i < limit; i++ {
+		if i == len(data) {
+			return 0, i, io.ErrUnexpectedEOF
+		}
+		switch b := data[i]; b {
+		case ':':
+			return size, i + 1, nil
+		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			size = 10*size + uint64(b-'0')
+		default:
+			return 0, i + 1, ErrInvalidSizeChar(b)
+		}
+	}
+	return 0, limit, ErrSizeLimitExceeded
+}
+
+// validate checks that data is exactly one well-formed tnetstring.
+func validate(data []byte) error {
+	payload, t, rest, err := split(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return ErrTrailingData
+	}
+	return validatePayload(t, payload)
+}
+
+func validatePayload(t byte, payload []byte) error {
+	if t != '}' && t != ']' {
+		return nil
+	}
+	for i := 0; len(payload) > 0; i++ {
+		p, u, rest, err := split(payload)
+		if err != nil {
+			return err
+		}
+		if t == '}' && i%2 == 0 && u != ',' && u != ';' {
+			return ErrNonStringKey
+		}
+		if err := validatePayload(u, p); err != nil {
+			return err
+		}
+		payload = rest
+		if t == '}' && i%2 == 0 && len(payload) == 0 {
+			return io.ErrUnexpectedEOF
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package tnetstrings
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	testCases := []struct {
+		title string
+		in    interface{}
+	}{
+		{
+			title: "int",
+			in:    1,
+		},
+		{
+			title: "string",
+			in:    "hello, world",
+		},
+		{
+			title: "map",
+			in: map[string]interface{}{
+				"foo": nil,
+				"bar": 1,
+			},
+		},
+		{
+			title: "slice",
+			in:    []string{"foo", "bar", "baz"},
+		},
+	}
+
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(tc.in); err != nil {
+			t.Fatal(err)
+		}
+		b, err := Marshal(tc.in)
+		if err != nil {
+			t.Errorf("[%s] %v", tc.title, err)
+		}
+		if !bytes.Equal(buf.Bytes(), b) {
+			t.Errorf("[%s] expected: %s, got: %s", tc.title, buf.Bytes(), b)
+		}
+		b, err = AppendMarshal([]byte("prefix"), tc.in)
+		if err != nil {
+			t.Errorf("[%s] %v", tc.title, err)
+		}
+		if expected := "prefix" + buf.String(); expected != string(b) {
+			t.Errorf("[%s] expected: %s, got: %s", tc.title, expected, b)
+		}
+	}
+
+	if _, err := Marshal(1i); !errors.Is(err, ErrUnsupportedType{Type: reflect.TypeOf(0i)}) {
+		t.Errorf("expected: %v, got: %v", ErrUnsupportedType{Type: reflect.TypeOf(0i)}, err)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	testCases := []struct {
+		title string
+		in    string
+		out   interface{}
+		err   error
+	}{
+		{
+			title: "string",
+			in:    "3:abc,",
+			out:   "abc",
+		},
+		{
+			title: "integer",
+			in:    "3:123#",
+			out:   int64(123),
+		},
+		{
+			title: "dictionary",
+			in:    "12:3:foo,3:bar,}",
+			out: map[string]interface{}{
+				"foo": "bar",
+			},
+		},
+		{
+			title: "list",
+			in:    "12:3:foo,3:bar,]",
+			out: []interface{}{
+				"foo",
+				"bar",
+			},
+		},
+		{
+			title: "empty input",
+			in:    "",
+			err:   &SyntaxError{Offset: 0, Err: io.ErrUnexpectedEOF},
+		},
+		{
+			title: "bigger size",
+			in:    "1000:foo,",
+			err:   &SyntaxError{Offset: 9, Err: io.ErrUnexpectedEOF},
+		},
+		{
+			title: "smaller size",
+			in:    "2:foo,",
+			err:   &SyntaxError{Offset: 4, Err: ErrInvalidTypeChar('o')},
+		},
+		{
+			title: "trailing data",
+			in:    "3:abc,0:~",
+			err:   &SyntaxError{Offset: 6, Err: ErrTrailingData},
+		},
+	}
+
+	for _, tc := range testCases {
+		var i interface{}
+		if err := Unmarshal([]byte(tc.in), &i); !reflect.DeepEqual(tc.err, err) {
+			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
+		}
+		if !reflect.DeepEqual(tc.out, i) {
+			t.Errorf("[%s] expected: %#v, got: %#v", tc.title, tc.out, i)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package tnetstrings
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// Number is an integer or a float kept as its literal text.
+// It's what numbers are decoded into for an interface{} destination when UseNumber is set.
+// It's encoded as an integer if it's decimal, otherwise as a float.
+type Number string
+
+// String returns the literal text of the number.
+func (n Number) String() string {
+	return string(n)
+}
+
+// Int64 returns the number as an int64.
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+// Float64 returns the number as a float64.
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// BigInt returns the number as a *big.Int.
+func (n Number) BigInt() (*big.Int, error) {
+	i, ok := new(big.Int).SetString(string(n), 10)
+	if !ok {
+		return nil, &strconv.NumError{Func: "BigInt", Num: string(n), Err: strconv.ErrSyntax}
+	}
+	return i, nil
+}
+
+var (
+	numberType   = reflect.TypeOf(Number(""))
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+	bigRatType   = reflect.TypeOf(big.Rat{})
+)
+
+// newNumberEncoder returns the encoderFunc for Number, big.Int, big.Float and big.Rat, or nil for other types.
+func newNumberEncoder(t reflect.Type) encoderFunc {
+	switch t {
+	case numberType:
+		return encodeNumber
+	case bigIntType:
+		return addrEncoder(encodeBigInt)
+	case bigFloatType:
+		return addrEncoder(encodeBigFloat)
+	case bigRatType:
+		return addrEncoder(encodeBigRat)
+	}
+	if t.Kind() == reflect.Ptr && newNumberEncoder(t.Elem()) != nil {
+		return newPtrEncoder(t)
+	}
+	return nil
+}
+
+func encodeNumber(s *encodeState, v reflect.Value) error {
+	n := v.String()
+	if n == "" {
+		n = "0"
+	}
+	if isDecimal([]byte(n)) {
+		s.prependTNetstring([]byte(n), '#')
+		return nil
+	}
+	f, err := strconv.ParseFloat(n, 64)
+	if err != nil && !isRangeError(err) {
+		return err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) && err == nil {
+		return encodeFloat(s, reflect.ValueOf(f))
+	}
+	s.prependTNetstring([]byte(n), '^')
+	return nil
+}
+
+// isRangeError reports whether err is strconv.ErrRange, which doesn't matter to a Number.
+func isRangeError(err error) bool {
+	e, ok := err.(*strconv.NumError)
+	return ok && e.Err == strconv.ErrRange
+}
+
+func encodeBigInt(s *encodeState, v reflect.Value) error {
+	var a [40]byte
+	s.prependTNetstring(v.Interface().(*big.Int).Append(a[:0], 10), '#')
+	return nil
+}
+
+func encodeBigFloat(s *encodeState, v reflect.Value) error {
+	f := v.Interface().(*big.Float)
+	if f.IsInf() {
+		return encodeFloat(s, reflect.ValueOf(math.Inf(f.Sign())))
+	}
+	var a [40]byte
+	s.prependTNetstring(f.Append(a[:0], 'g', -1), '^')
+	return nil
+}
+
+// encodeBigRat encodes a rational number as a float with the exact decimal representation.
+func encodeBigRat(s *encodeState, v reflect.Value) error {
+	r := v.Interface().(*big.Rat)
+	n, ok := fractionDigits(r.Denom())
+	if !ok {
+		return ErrInexactDecimal
+	}
+	s.prependTNetstring([]byte(r.FloatString(n)), '^')
+	return nil
+}
+
+// fractionDigits returns the number of digits after the decimal point needed to represent a fraction with
+// the denominator d exactly. It's false if d has a prime factor other than 2 and 5.
+func fractionDigits(d *big.Int) (int, bool) {
+	q := new(big.Int).Set(d)
+	twos := int(q.TrailingZeroBits())
+	q.Rsh(q, uint(twos))
+	var fives int
+	five, p, m := big.NewInt(5), new(big.Int), new(big.Int)
+	for q.Cmp(five) >= 0 {
+		if p.QuoRem(q, five, m); m.Sign() != 0 {
+			break
+		}
+		q, p = p, q
+		fives++
+	}
+	if q.Cmp(big.NewInt(1)) != 0 {
+		return 0, false
+	}
+	if twos > fives {
+		return twos, true
+	}
+	return fives, true
+}
+
+// newNumberDecoder returns the decoderFunc for Number, big.Int, big.Float and big.Rat, or nil for other types.
+// Pointers to them are handled by the pointer decoder.
+func newNumberDecoder(t reflect.Type) decoderFunc {
+	switch t {
+	case numberType:
+		return decodeNumber
+	case bigIntType, bigFloatType, bigRatType:
+		return decodeBig
+	}
+	return nil
+}
+
+func decodeNumber(_ *decodeState, t byte, data []byte, _ int64, rv reflect.Value) error {
+	switch t {
+	case '#', '^':
+		rv.SetString(string(data))
+	case '~':
+		rv.SetString("")
+	default:
+		return ErrUnsupportedType{Type: rv.Type()}
+	}
+	return nil
+}
+
+// decodeBig decodes an integer or a float into a big.Int, a big.Float or a big.Rat.
+// Strings are decoded with their encoding.TextUnmarshaler.
+func decodeBig(d *decodeState, t byte, data []byte, _ int64, rv reflect.Value) error {
+	if t == '~' {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	if !rv.CanAddr() {
+		return ErrUnsupportedType{Type: rv.Type()}
+	}
+	var ok bool
+	switch v := rv.Addr().Interface().(type) {
+	case *big.Int:
+		switch t {
+		case ',', ';':
+			return v.UnmarshalText(data)
+		case '#':
+			_, ok = v.SetString(string(data), 10)
+		default:
+			return ErrUnsupportedType{Type: rv.Type()}
+		}
+	case *big.Float:
+		switch t {
+		case ',', ';':
+			return v.UnmarshalText(data)
+		case '#', '^':
+			if v.Prec() == 0 {
+				// Keep every digit of an integer; 4 bits are more than enough for a decimal digit.
+				v.SetPrec(64)
+				if p := uint(4 * len(data)); p > 64 {
+					v.SetPrec(p)
+				}
+			}
+			_, ok = v.SetString(string(data))
+			if ok && v.IsInf() && d.opts.NonFinite != NonFiniteTokens {
+				return ErrNonFinite
+			}
+		default:
+			return ErrUnsupportedType{Type: rv.Type()}
+		}
+	case *big.Rat:
+		switch t {
+		case ',', ';':
+			return v.UnmarshalText(data)
+		case '#', '^':
+			_, ok = v.SetString(string(data))
+		default:
+			return ErrUnsupportedType{Type: rv.Type()}
+		}
+	}
+	if !ok {
+		return &strconv.NumError{Func: "SetString", Num: string(data), Err: strconv.ErrSyntax}
+	}
+	return nil
+}
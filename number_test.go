@@ -0,0 +1,99 @@
+package tnetstrings
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+const testBigInt = "170141183460469231731687303715884105729" // 2^127 + 1
+
+func TestMarshal_number(t *testing.T) {
+	i, _ := new(big.Int).SetString(testBigInt, 10)
+	testCases := []struct {
+		title string
+		in    interface{}
+		out   string
+		err   error
+	}{
+		{title: "big.Int", in: i, out: "39:" + testBigInt + "#"},
+		{title: "big.Int value", in: *big.NewInt(-42), out: "3:-42#"},
+		{title: "nil big.Int", in: (*big.Int)(nil), out: "0:~"},
+		{title: "big.Int slice", in: []*big.Int{i, nil}, out: "46:39:" + testBigInt + "#0:~]"},
+		{title: "big.Float", in: big.NewFloat(1.5), out: "3:1.5^"},
+		{title: "big.Rat", in: big.NewRat(5, 4), out: "4:1.25^"},
+		{title: "integral big.Rat", in: big.NewRat(2, 1), out: "1:2^"},
+		{title: "big.Rat of fives", in: big.NewRat(1, 125), out: "5:0.008^"},
+		{title: "big.Rat of twos and fives", in: big.NewRat(3, 40), out: "5:0.075^"},
+		{title: "inexact big.Rat", in: big.NewRat(1, 3), err: ErrInexactDecimal},
+		{title: "inexact big.Rat of fives", in: big.NewRat(1, 75), err: ErrInexactDecimal},
+		{title: "integer Number", in: Number("12"), out: "2:12#"},
+		{title: "float Number", in: Number("1.5e-3"), out: "6:1.5e-3^"},
+		{title: "empty Number", in: Number(""), out: "1:0#"},
+	}
+
+	for _, tc := range testCases {
+		b, err := Marshal(tc.in)
+		if !isError(err, tc.err) {
+			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
+		}
+		if string(b) != tc.out {
+			t.Errorf("[%s] expected: %s, got: %s", tc.title, tc.out, b)
+		}
+	}
+
+	if _, err := Marshal(Number("x")); err == nil {
+		t.Error("expected an error for an invalid Number")
+	}
+}
+
+func TestUnmarshal_number(t *testing.T) {
+	var i big.Int
+	if err := Unmarshal([]byte("39:"+testBigInt+"#"), &i); err != nil || i.String() != testBigInt {
+		t.Errorf("expected: %s, got: %s, %v", testBigInt, &i, err)
+	}
+	var p *big.Int
+	if err := Unmarshal([]byte("3:-42#"), &p); err != nil || p.Int64() != -42 {
+		t.Errorf("expected: -42, got: %s, %v", p, err)
+	}
+	var f big.Float
+	if err := Unmarshal([]byte("39:"+testBigInt+"#"), &f); err != nil || f.Text('f', 0) != testBigInt {
+		t.Errorf("expected: %s, got: %s, %v", testBigInt, f.Text('f', 0), err)
+	}
+	var r big.Rat
+	if err := Unmarshal([]byte("4:1.25^"), &r); err != nil || r.Cmp(big.NewRat(5, 4)) != 0 {
+		t.Errorf("expected: 5/4, got: %s, %v", &r, err)
+	}
+	if err := Unmarshal([]byte("4:1.25^"), &i); !isError(err, ErrUnsupportedType{Type: reflect.TypeOf(i)}) {
+		t.Errorf("expected: %v, got: %v", ErrUnsupportedType{Type: reflect.TypeOf(i)}, err)
+	}
+
+	var v interface{}
+	if err := (DecoderOptions{UseNumber: true}).Unmarshal([]byte("39:"+testBigInt+"#"), &v); err != nil {
+		t.Fatal(err)
+	}
+	n, ok := v.(Number)
+	if !ok || n.String() != testBigInt {
+		t.Fatalf("expected: Number(%s), got: %#v", testBigInt, v)
+	}
+	if b, err := n.BigInt(); err != nil || b.String() != testBigInt {
+		t.Errorf("expected: %s, got: %s, %v", testBigInt, b, err)
+	}
+	if _, err := n.Int64(); err == nil {
+		t.Error("expected an error for an int64 overflow")
+	}
+
+	d := NewDecoder(bytes.NewReader([]byte("18:1:a,3:1.5^1:b,1:7#}")))
+	d.UseNumber()
+	var m map[string]interface{}
+	if err := d.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]interface{}{"a": Number("1.5"), "b": Number("7")}; !reflect.DeepEqual(expected, m) {
+		t.Errorf("expected: %#v, got: %#v", expected, m)
+	}
+	if f, err := m["a"].(Number).Float64(); err != nil || f != 1.5 {
+		t.Errorf("expected: 1.5, got: %v, %v", f, err)
+	}
+}
@@ -0,0 +1,66 @@
+package tnetstrings
+
+import "io"
+
+// Profile bundles the encoder and decoder options for talking to a particular tnetstrings implementation.
+type Profile struct {
+	Name    string
+	Encoder EncoderOptions
+	Decoder DecoderOptions
+}
+
+var (
+	// ProfileClassic follows the original spec: every string is `,`, NaN and ±Inf are rejected,
+	// and the decoder accepts nothing but the spec.
+	ProfileClassic = Profile{
+		Name:    "classic",
+		Encoder: EncoderOptions{Dialect: Classic},
+		Decoder: DecoderOptions{Strict: true},
+	}
+
+	// ProfileTnetstring3 is for the Python tnetstring3 package: strings are `;` and bytes are `,`,
+	// which are decoded into an interface{} as a string and a []byte respectively. NaN and ±Inf are tokens.
+	ProfileTnetstring3 = Profile{
+		Name:    "tnetstring3",
+		Encoder: EncoderOptions{Dialect: TNetstring3, NonFinite: NonFiniteTokens},
+		Decoder: DecoderOptions{Strict: true, ByteStrings: TargetBytes, NonFinite: NonFiniteTokens},
+	}
+
+	// ProfileMongrel2 is for Mongrel2 and its handlers: every string is `,` and floats are written
+	// in plain decimal notation without an exponent. The decoder is lenient about booleans and nulls.
+	ProfileMongrel2 = Profile{
+		Name:    "mongrel2",
+		Encoder: EncoderOptions{Dialect: Classic, FloatFormat: 'f', FloatPrecision: -1},
+	}
+
+	// ProfileJavaScript is for JavaScript peers: every string is `,` and NaN and ±Inf become null
+	// as they do in JSON.stringify. The decoder is lenient about booleans and nulls.
+	ProfileJavaScript = Profile{
+		Name:    "javascript",
+		Encoder: EncoderOptions{Dialect: Classic, NonFinite: NonFiniteNull},
+	}
+)
+
+func (p Profile) String() string {
+	return p.Name
+}
+
+// NewEncoder returns a new Encoder with the encoder options of the profile.
+func (p Profile) NewEncoder(w io.Writer) *Encoder {
+	return p.Encoder.NewEncoder(w)
+}
+
+// NewDecoder returns a new Decoder with the decoder options of the profile.
+func (p Profile) NewDecoder(r io.Reader) *Decoder {
+	return p.Decoder.NewDecoder(r)
+}
+
+// Marshal returns the tnetstring encoding of val with the encoder options of the profile.
+func (p Profile) Marshal(val interface{}) ([]byte, error) {
+	return p.Encoder.Marshal(val)
+}
+
+// Unmarshal decodes exactly one tnetstring from data into val with the decoder options of the profile.
+func (p Profile) Unmarshal(data []byte, val interface{}) error {
+	return p.Decoder.Unmarshal(data, val)
+}
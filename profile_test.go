@@ -0,0 +1,138 @@
+package tnetstrings
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// testProfiles are the columns of the conformance vectors below.
+var testProfiles = []Profile{ProfileClassic, ProfileTnetstring3, ProfileMongrel2, ProfileJavaScript}
+
+// testEncoded is the expected encoding or the expected error.
+type testEncoded struct {
+	out string
+	err error
+}
+
+// testDecoded is the expected value decoded into an interface{} or the expected error.
+type testDecoded struct {
+	val interface{}
+	err error
+}
+
+func TestProfile_Marshal(t *testing.T) {
+	testCases := []struct {
+		title    string
+		in       interface{}
+		expected [4]testEncoded
+	}{
+		{
+			title:    "string",
+			in:       "hi",
+			expected: [4]testEncoded{{out: "2:hi,"}, {out: "2:hi;"}, {out: "2:hi,"}, {out: "2:hi,"}},
+		},
+		{
+			title:    "bytes",
+			in:       []byte("hi"),
+			expected: [4]testEncoded{{out: "2:hi,"}, {out: "2:hi,"}, {out: "2:hi,"}, {out: "2:hi,"}},
+		},
+		{
+			title:    "dictionary",
+			in:       map[string]string{"a": "b"},
+			expected: [4]testEncoded{{out: "8:1:a,1:b,}"}, {out: "8:1:a;1:b;}"}, {out: "8:1:a,1:b,}"}, {out: "8:1:a,1:b,}"}},
+		},
+		{
+			title:    "float",
+			in:       0.5,
+			expected: [4]testEncoded{{out: "3:0.5^"}, {out: "3:0.5^"}, {out: "3:0.5^"}, {out: "3:0.5^"}},
+		},
+		{
+			title:    "small float",
+			in:       1e-9,
+			expected: [4]testEncoded{{out: "5:1e-09^"}, {out: "5:1e-09^"}, {out: "11:0.000000001^"}, {out: "5:1e-09^"}},
+		},
+		{
+			title:    "NaN",
+			in:       math.NaN(),
+			expected: [4]testEncoded{{err: ErrNonFinite}, {out: "3:NaN^"}, {err: ErrNonFinite}, {out: "0:~"}},
+		},
+		{
+			title:    "boolean",
+			in:       true,
+			expected: [4]testEncoded{{out: "4:true!"}, {out: "4:true!"}, {out: "4:true!"}, {out: "4:true!"}},
+		},
+		{
+			title:    "null",
+			in:       nil,
+			expected: [4]testEncoded{{out: "0:~"}, {out: "0:~"}, {out: "0:~"}, {out: "0:~"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		for i, p := range testProfiles {
+			e := tc.expected[i]
+			b, err := p.Marshal(tc.in)
+			if !isError(err, e.err) {
+				t.Errorf("[%s] %s: expected: %v, got: %v", tc.title, p, e.err, err)
+			}
+			if string(b) != e.out {
+				t.Errorf("[%s] %s: expected: %s, got: %s", tc.title, p, e.out, b)
+			}
+		}
+	}
+}
+
+func TestProfile_Unmarshal(t *testing.T) {
+	testCases := []struct {
+		title    string
+		in       string
+		expected [4]testDecoded
+	}{
+		{
+			title:    "byte string",
+			in:       "2:hi,",
+			expected: [4]testDecoded{{val: "hi"}, {val: []byte("hi")}, {val: "hi"}, {val: "hi"}},
+		},
+		{
+			title:    "text string",
+			in:       "2:hi;",
+			expected: [4]testDecoded{{val: "hi"}, {val: "hi"}, {val: "hi"}, {val: "hi"}},
+		},
+		{
+			title: "loose boolean",
+			in:    "1:1!",
+			expected: [4]testDecoded{
+				{err: ErrInvalidBoolean}, {err: ErrInvalidBoolean}, {val: true}, {val: true},
+			},
+		},
+		{
+			title: "null with a payload",
+			in:    "3:abc~",
+			expected: [4]testDecoded{
+				{err: ErrNonEmptyNull}, {err: ErrNonEmptyNull}, {val: nil}, {val: nil},
+			},
+		},
+		{
+			title: "infinity",
+			in:    "4:+Inf^",
+			expected: [4]testDecoded{
+				{err: ErrNonFinite}, {val: math.Inf(1)}, {err: ErrNonFinite}, {err: ErrNonFinite},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		for i, p := range testProfiles {
+			e := tc.expected[i]
+			var v interface{}
+			err := p.Unmarshal([]byte(tc.in), &v)
+			if !isError(err, e.err) {
+				t.Errorf("[%s] %s: expected: %v, got: %v", tc.title, p, e.err, err)
+			}
+			if !reflect.DeepEqual(e.val, v) {
+				t.Errorf("[%s] %s: expected: %#v, got: %#v", tc.title, p, e.val, v)
+			}
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package tnetstrings
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Get returns the tnetstring found by following keys from the tnetstring in data.
+// Each key selects a dictionary entry, or a list item if it's an integer.
+// The result refers to data and it doesn't allocate since the irrelevant parts are skipped by their SIZE.
+// Use Unmarshal or Parse on the result to get a typed value.
+func Get(data []byte, keys ...string) (RawMessage, error) {
+	if _, _, _, err := splitTop(data); err != nil {
+		return nil, err
+	}
+	v, err := get(data, 0, keys)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// get follows keys from the tnetstring raw found at offset off.
+func get(raw []byte, off int64, keys []string) ([]byte, error) {
+	if len(keys) == 0 {
+		return raw, nil
+	}
+	v, vOff, t, err := child(raw, off, keys[0])
+	if err != nil {
+		return nil, err
+	}
+	if v, err = get(v, vOff, keys[1:]); err != nil {
+		return nil, withPath(err, childElem(t, keys[0]))
+	}
+	return v, nil
+}
+
+// child returns the dictionary entry or the list item selected by key of the tnetstring raw found at offset off
+// along with the offset of the result and the type char of raw.
+func child(raw []byte, off int64, key string) ([]byte, int64, byte, error) {
+	s := scanner{data: raw, off: off}
+	t, payload, pOff, err := s.next()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	s = scanner{data: payload, off: pOff}
+	switch t {
+	case '}':
+		for s.more() {
+			_, k, _, err := s.key()
+			if err != nil {
+				return nil, 0, t, err
+			}
+			v, _, vOff, err := s.raw()
+			if err != nil {
+				return nil, 0, t, withPath(err, keyElem(k))
+			}
+			if string(k) == key {
+				return v, vOff, t, nil
+			}
+		}
+		return nil, 0, t, ErrNotFound
+	case ']':
+		i, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, 0, t, ErrNotFound
+		}
+		for j := 0; s.more(); j++ {
+			v, _, vOff, err := s.raw()
+			if err != nil {
+				return nil, 0, t, withPath(err, indexElem(j))
+			}
+			if j == i {
+				return v, vOff, t, nil
+			}
+		}
+		return nil, 0, t, ErrNotFound
+	default:
+		return nil, 0, t, ErrTypeMismatch(t)
+	}
+}
+
+// childElem returns key as a path element of a dictionary or a list of the type char t.
+func childElem(t byte, key string) string {
+	if t == ']' {
+		return "[" + key + "]"
+	}
+	return keyElem([]byte(key))
+}
+
+// Query returns the tnetstrings found by following path from the tnetstring in data.
+//
+// A path is a sequence of keys separated by `.` like `headers.user.id`. A key can also be written in brackets
+// like `items[0]` or `headers["content.type"]` with a quoted string. `*` selects every dictionary value
+// or list item. A path without any match returns no results and no error.
+func Query(data []byte, path string) ([]RawMessage, error) {
+	segs, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, _, err := splitTop(data); err != nil {
+		return nil, err
+	}
+	var results []RawMessage
+	if err := query(data, 0, segs, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// query appends the tnetstrings found by following segs from the tnetstring raw found at offset off to results.
+func query(raw []byte, off int64, segs []segment, results *[]RawMessage) error {
+	if len(segs) == 0 {
+		*results = append(*results, raw)
+		return nil
+	}
+
+	seg := segs[0]
+	if !seg.wildcard {
+		v, vOff, t, err := child(raw, off, seg.key)
+		switch err {
+		case nil:
+			if err := query(v, vOff, segs[1:], results); err != nil {
+				return withPath(err, childElem(t, seg.key))
+			}
+			return nil
+		case ErrNotFound:
+			return nil
+		default:
+			if _, ok := err.(ErrTypeMismatch); ok {
+				return nil
+			}
+			return err
+		}
+	}
+
+	s := scanner{data: raw, off: off}
+	t, payload, pOff, err := s.next()
+	if err != nil {
+		return err
+	}
+	if t != '}' && t != ']' {
+		return nil
+	}
+	s = scanner{data: payload, off: pOff}
+	for i := 0; s.more(); i++ {
+		var k []byte
+		if t == '}' {
+			if _, k, _, err = s.key(); err != nil {
+				return err
+			}
+		}
+		v, _, vOff, err := s.raw()
+		if err == nil {
+			err = query(v, vOff, segs[1:], results)
+		}
+		if err != nil {
+			if t == '}' {
+				return withPath(err, keyElem(k))
+			}
+			return withPath(err, indexElem(i))
+		}
+	}
+	return nil
+}
+
+// segment is a key in a query path.
+type segment struct {
+	key      string
+	wildcard bool
+}
+
+func parsePath(path string) ([]segment, error) {
+	var segs []segment
+	for i := 0; i < len(path); {
+		if path[i] == '[' {
+			seg, n, err := parseBracket(path[i:])
+			if err != nil {
+				return nil, ErrInvalidPath(path)
+			}
+			segs = append(segs, seg)
+			i += n
+			continue
+		}
+		if len(segs) > 0 {
+			if path[i] != '.' {
+				return nil, ErrInvalidPath(path)
+			}
+			i++
+		}
+		j := i
+		for j < len(path) && path[j] != '.' && path[j] != '[' {
+			j++
+		}
+		if j == i {
+			return nil, ErrInvalidPath(path)
+		}
+		segs = append(segs, newSegment(path[i:j]))
+		i = j
+	}
+	return segs, nil
+}
+
+// parseBracket parses a key in brackets at the beginning of path and returns it along with its length.
+func parseBracket(path string) (segment, int, error) {
+	if len(path) < 2 || path[1] != '"' {
+		end := strings.IndexByte(path, ']')
+		if end < 0 {
+			return segment{}, 0, ErrInvalidPath(path)
+		}
+		return newSegment(path[1:end]), end + 1, nil
+	}
+	for i := 2; i < len(path); i++ {
+		switch path[i] {
+		case '\\':
+			i++
+		case '"':
+			key, err := strconv.Unquote(path[1 : i+1])
+			if err != nil || i+1 >= len(path) || path[i+1] != ']' {
+				return segment{}, 0, ErrInvalidPath(path)
+			}
+			return segment{key: key}, i + 2, nil
+		}
+	}
+	return segment{}, 0, ErrInvalidPath(path)
+}
+
+func newSegment(key string) segment {
+	return segment{key: key, wildcard: key == "*"}
+}
@@ -0,0 +1,155 @@
+package tnetstrings
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testQueryData = `126:7:headers,50:4:user,25:2:id,2:42#4:name,5:alice,}7:a.b[c]",1:x,}5:items,50:13:4:name,3:foo,}13:4:name,3:bar,}12:5:other,1:1#}]}`
+
+func TestGet(t *testing.T) {
+	testCases := []struct {
+		title string
+		keys  []string
+		out   string
+		err   error
+	}{
+		{
+			title: "whole",
+			out:   testQueryData,
+		},
+		{
+			title: "nested dictionary",
+			keys:  []string{"headers", "user", "id"},
+			out:   "2:42#",
+		},
+		{
+			title: "list item",
+			keys:  []string{"items", "1", "name"},
+			out:   "3:bar,",
+		},
+		{
+			title: "missing key",
+			keys:  []string{"headers", "missing"},
+			err:   ErrNotFound,
+		},
+		{
+			title: "out of range",
+			keys:  []string{"items", "3"},
+			err:   ErrNotFound,
+		},
+		{
+			title: "scalar",
+			keys:  []string{"headers", "user", "id", "foo"},
+			err:   ErrTypeMismatch('#'),
+		},
+	}
+
+	for _, tc := range testCases {
+		raw, err := Get([]byte(testQueryData), tc.keys...)
+		if err != tc.err {
+			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
+		}
+		if string(raw) != tc.out {
+			t.Errorf("[%s] expected: %s, got: %s", tc.title, tc.out, raw)
+		}
+	}
+
+	raw, err := Get([]byte(testQueryData), "headers", "user", "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id int
+	if err := Unmarshal(raw, &id); err != nil || id != 42 {
+		t.Errorf("expected: 42, got: %d %v", id, err)
+	}
+
+	data := []byte(testQueryData)
+	if n := testing.AllocsPerRun(100, func() {
+		_, _ = Get(data, "items", "2", "other")
+	}); n != 0 {
+		t.Errorf("expected no allocations, got: %f", n)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	testCases := []struct {
+		title string
+		path  string
+		out   []string
+		err   error
+	}{
+		{
+			title: "dotted",
+			path:  "headers.user.name",
+			out:   []string{"5:alice,"},
+		},
+		{
+			title: "index",
+			path:  "items[0].name",
+			out:   []string{"3:foo,"},
+		},
+		{
+			title: "quoted",
+			path:  `headers["a.b[c]\""]`,
+			out:   []string{"1:x,"},
+		},
+		{
+			title: "wildcard list",
+			path:  "items[*].name",
+			out:   []string{"3:foo,", "3:bar,"},
+		},
+		{
+			title: "wildcard dictionary",
+			path:  "headers.*.id",
+			out:   []string{"2:42#"},
+		},
+		{
+			title: "no match",
+			path:  "headers.missing",
+		},
+		{
+			title: "invalid",
+			path:  "items[0",
+			err:   ErrInvalidPath("items[0"),
+		},
+		{
+			title: "empty key",
+			path:  "headers..user",
+			err:   ErrInvalidPath("headers..user"),
+		},
+	}
+
+	for _, tc := range testCases {
+		results, err := Query([]byte(testQueryData), tc.path)
+		if err != tc.err {
+			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
+		}
+		var out []string
+		for _, r := range results {
+			out = append(out, string(r))
+		}
+		if !reflect.DeepEqual(tc.out, out) {
+			t.Errorf("[%s] expected: %q, got: %q", tc.title, tc.out, out)
+		}
+	}
+}
+
+func TestGet_syntaxError(t *testing.T) {
+	data := []byte("25:7:headers,11:4:user,1:x?}}")
+	expected := &SyntaxError{Offset: 26, Path: "headers.user", Err: ErrInvalidTypeChar('?')}
+
+	if _, err := Get(data, "headers", "user"); !reflect.DeepEqual(expected, err) {
+		t.Errorf("expected: %v, got: %v", expected, err)
+	}
+	for _, path := range []string{"headers.user", "*.*"} {
+		if _, err := Query(data, path); !reflect.DeepEqual(expected, err) {
+			t.Errorf("[%s] expected: %v, got: %v", path, expected, err)
+		}
+	}
+
+	expected = &SyntaxError{Offset: 3, Err: ErrTrailingData}
+	if _, err := Get([]byte("0:~0:~")); !reflect.DeepEqual(expected, err) {
+		t.Errorf("expected: %v, got: %v", expected, err)
+	}
+}
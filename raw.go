@@ -0,0 +1,24 @@
+package tnetstrings
+
+import "errors"
+
+// RawMessage is a raw encoded tnetstring. It implements Marshaler and Unmarshaler
+// so that it can be used to delay decoding or to encode a precomputed tnetstring.
+type RawMessage []byte
+
+// MarshalTNetstring returns m as the tnetstring of m. A nil RawMessage is a null.
+func (m RawMessage) MarshalTNetstring() ([]byte, error) {
+	if m == nil {
+		return []byte("0:~"), nil
+	}
+	return m, nil
+}
+
+// UnmarshalTNetstring sets *m to a copy of data.
+func (m *RawMessage) UnmarshalTNetstring(data []byte) error {
+	if m == nil {
+		return errors.New("tnetstrings.RawMessage: UnmarshalTNetstring on nil pointer")
+	}
+	*m = append((*m)[0:0], data...)
+	return nil
+}
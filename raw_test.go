@@ -0,0 +1,90 @@
+package tnetstrings
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRawMessage(t *testing.T) {
+	type envelope struct {
+		Kind string
+		Body RawMessage
+	}
+
+	testCases := []struct {
+		title string
+		in    string
+		out   interface{}
+	}{
+		{
+			title: "struct field",
+			in:    "46:4:Kind,3:baz,4:Body,22:3:foo;4:true!3:bar,0:]}}",
+			out: &envelope{
+				Kind: "baz",
+				Body: RawMessage("22:3:foo;4:true!3:bar,0:]}"),
+			},
+		},
+		{
+			title: "map value",
+			in:    "14:3:foo,5:hello,}",
+			out:   &map[string]RawMessage{"foo": RawMessage("5:hello,")},
+		},
+		{
+			title: "list element",
+			in:    "12:1:1#0:~2:42#]",
+			out:   &[]RawMessage{RawMessage("1:1#"), RawMessage("0:~"), RawMessage("2:42#")},
+		},
+		{
+			title: "whole",
+			in:    "9:3:foo,0:]]",
+			out:   func() *RawMessage { m := RawMessage("9:3:foo,0:]]"); return &m }(),
+		},
+		{
+			title: "non-canonical size",
+			in:    "10:1:R,02:ab,}",
+			out:   &map[string]RawMessage{"R": RawMessage("02:ab,")},
+		},
+		{
+			title: "whole non-canonical size",
+			in:    "002:ab,",
+			out:   func() *RawMessage { m := RawMessage("002:ab,"); return &m }(),
+		},
+	}
+
+	for _, tc := range testCases {
+		out := reflect.New(reflect.TypeOf(tc.out).Elem())
+		if err := Unmarshal([]byte(tc.in), out.Interface()); err != nil {
+			t.Errorf("[%s] %v", tc.title, err)
+		}
+		if !reflect.DeepEqual(tc.out, out.Interface()) {
+			t.Errorf("[%s] expected: %#v, got: %#v", tc.title, tc.out, out.Interface())
+		}
+		out = reflect.New(reflect.TypeOf(tc.out).Elem())
+		if err := NewDecoder(strings.NewReader(tc.in)).Decode(out.Interface()); err != nil {
+			t.Errorf("[%s] %v", tc.title, err)
+		}
+		if !reflect.DeepEqual(tc.out, out.Interface()) {
+			t.Errorf("[%s] expected: %#v, got: %#v", tc.title, tc.out, out.Interface())
+		}
+		b, err := Marshal(out.Interface())
+		if err != nil {
+			t.Errorf("[%s] %v", tc.title, err)
+		}
+		if string(b) != tc.in {
+			t.Errorf("[%s] expected: %s, got: %s", tc.title, tc.in, b)
+		}
+	}
+
+	b, err := Marshal(envelope{Kind: "baz"})
+	if err != nil {
+		t.Error(err)
+	}
+	if expected := "23:4:Kind,3:baz,4:Body,0:~}"; string(b) != expected {
+		t.Errorf("expected: %s, got: %s", expected, b)
+	}
+
+	if _, err := Marshal(RawMessage("3:foo")); err == nil {
+		t.Error("expected an error for an invalid RawMessage")
+	}
+}
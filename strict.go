@@ -0,0 +1,109 @@
+package tnetstrings
+
+import "unicode/utf8"
+
+// checkStrict checks the tnetstring which starts at offset start and has the type char t and the payload data
+// found at offset off against the rules of DecoderOptions.Strict.
+// The depth and item limits are applied as it goes so that hostile input is rejected before it's walked through.
+func (d *decodeState) checkStrict(start int64, t byte, data []byte, off int64) error {
+	if err := checkCanonicalSize(start, off, len(data)); err != nil {
+		return err
+	}
+	switch t {
+	case ';':
+		if !utf8.Valid(data) {
+			return &SyntaxError{Offset: off, Err: ErrInvalidUTF8}
+		}
+	case '#':
+		if !isDecimal(data) {
+			return &SyntaxError{Offset: off, Err: ErrInvalidInteger}
+		}
+	case '!':
+		if s := string(data); s != "true" && s != "false" {
+			return &SyntaxError{Offset: off, Err: ErrInvalidBoolean}
+		}
+	case '~':
+		if len(data) != 0 {
+			return &SyntaxError{Offset: off, Err: ErrNonEmptyNull}
+		}
+	case '}':
+		if err := d.enter(off); err != nil {
+			return err
+		}
+		defer d.leave()
+
+		s := scanner{data: data, off: off}
+		for i := 0; s.more(); i++ {
+			if err := d.checkItems(i, s.off); err != nil {
+				return err
+			}
+			start := s.off
+			u, key, kOff, err := s.key()
+			if err != nil {
+				return err
+			}
+			elem := keyElem(key)
+			if err := d.checkStrict(start, u, key, kOff); err != nil {
+				return withPath(err, elem)
+			}
+			start = s.off
+			u, p, pOff, err := s.next()
+			if err != nil {
+				return withPath(err, elem)
+			}
+			if err := d.checkStrict(start, u, p, pOff); err != nil {
+				return withPath(err, elem)
+			}
+		}
+	case ']':
+		if err := d.enter(off); err != nil {
+			return err
+		}
+		defer d.leave()
+
+		s := scanner{data: data, off: off}
+		for i := 0; s.more(); i++ {
+			if err := d.checkItems(i, s.off); err != nil {
+				return err
+			}
+			start := s.off
+			u, p, pOff, err := s.next()
+			if err == nil {
+				err = d.checkStrict(start, u, p, pOff)
+			}
+			if err != nil {
+				return withPath(err, indexElem(i))
+			}
+		}
+	}
+	return nil
+}
+
+// checkCanonicalSize checks that SIZE of a tnetstring starting at offset start with the payload of size bytes
+// at offset off has no leading zeros.
+func checkCanonicalSize(start, off int64, size int) error {
+	digits := 1
+	for n := size; n >= 10; n /= 10 {
+		digits++
+	}
+	if off-start-1 != int64(digits) {
+		return &SyntaxError{Offset: start, Err: ErrNonCanonicalSize}
+	}
+	return nil
+}
+
+// isDecimal reports whether data is a decimal integer with an optional minus sign and no leading zeros.
+func isDecimal(data []byte) bool {
+	if len(data) > 0 && data[0] == '-' {
+		data = data[1:]
+	}
+	if len(data) == 0 || len(data) > 1 && data[0] == '0' {
+		return false
+	}
+	for _, b := range data {
+		if b < '0' || b > '9' {
+			return false
+		}
+	}
+	return true
+}
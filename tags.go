@@ -6,13 +6,19 @@ import (
 )
 
 type tag struct {
-	name        string
 	displayName string
+	named       bool
 	omitEmpty   bool
+	omitZero    bool
+	remain      bool
+	inline      bool
+
+	timeLayout    TimeLayout
+	hasTimeLayout bool
 }
 
 func parseTag(f reflect.StructField) *tag {
-	t := tag{name: f.Name, displayName: f.Name}
+	t := tag{displayName: f.Name}
 	if tnetstrings, ok := f.Tag.Lookup("tnetstrings"); ok {
 		if tnetstrings == "-" {
 			return nil
@@ -20,8 +26,26 @@ func parseTag(f reflect.StructField) *tag {
 		ts := strings.Split(tnetstrings, ",")
 		if len(ts) > 0 && ts[0] != "" {
 			t.displayName = ts[0]
+			t.named = true
+		}
+		for _, o := range ts[1:] {
+			switch o {
+			case "omitempty":
+				t.omitEmpty = true
+			case "omitzero":
+				t.omitZero = true
+			case "remain":
+				t.remain = true
+			case "inline":
+				t.inline = true
+			case "rfc3339":
+				t.timeLayout, t.hasTimeLayout = TimeRFC3339, true
+			case "unix":
+				t.timeLayout, t.hasTimeLayout = TimeUnix, true
+			case "unixnano":
+				t.timeLayout, t.hasTimeLayout = TimeUnixNano, true
+			}
 		}
-		t.omitEmpty = len(ts) > 1 && ts[1] == "omitempty"
 	}
 	return &t
 }
@@ -0,0 +1,187 @@
+package tnetstrings
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// TimeLayout is how time.Time and time.Duration are encoded.
+// In a struct tag it's one of the options `rfc3339`, `unix` and `unixnano`.
+type TimeLayout int
+
+const (
+	// TimeRFC3339 encodes a time.Time as a string in RFC 3339 with nanoseconds
+	// and a time.Duration as an integer of nanoseconds.
+	TimeRFC3339 TimeLayout = iota
+
+	// TimeUnix encodes a time.Time as an integer of seconds since the Unix epoch dropping the fraction
+	// and a time.Duration as a float of seconds.
+	TimeUnix
+
+	// TimeUnixNano encodes a time.Time as an integer of nanoseconds since the Unix epoch
+	// and a time.Duration as an integer of nanoseconds.
+	TimeUnixNano
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// newTimeEncoder returns the encoderFunc for time.Time, time.Duration and pointers to them, or nil for other types.
+// If layout is nil, the one of the EncoderOptions is used.
+func newTimeEncoder(t reflect.Type, layout *TimeLayout) encoderFunc {
+	switch t {
+	case timeType:
+		return func(s *encodeState, v reflect.Value) error {
+			return encodeTime(s, v.Interface().(time.Time), s.timeLayout(layout))
+		}
+	case durationType:
+		return func(s *encodeState, v reflect.Value) error {
+			return encodeDuration(s, time.Duration(v.Int()), s.timeLayout(layout))
+		}
+	}
+	if t.Kind() == reflect.Ptr {
+		if elem := newTimeEncoder(t.Elem(), layout); elem != nil {
+			return ptrEncoder(elem)
+		}
+	}
+	return nil
+}
+
+func (s *encodeState) timeLayout(layout *TimeLayout) TimeLayout {
+	if layout != nil {
+		return *layout
+	}
+	return s.opts.TimeLayout
+}
+
+func encodeTime(s *encodeState, t time.Time, layout TimeLayout) error {
+	var a [40]byte
+	switch layout {
+	case TimeUnix:
+		s.prependTNetstring(strconv.AppendInt(a[:0], t.Unix(), 10), '#')
+	case TimeUnixNano:
+		s.prependTNetstring(strconv.AppendInt(a[:0], t.UnixNano(), 10), '#')
+	default:
+		b, err := t.MarshalText()
+		if err != nil {
+			return err
+		}
+		s.prependTNetstring(b, s.stringChar())
+	}
+	return nil
+}
+
+func encodeDuration(s *encodeState, d time.Duration, layout TimeLayout) error {
+	var a [32]byte
+	if layout == TimeUnix {
+		s.prependTNetstring(strconv.AppendFloat(a[:0], d.Seconds(), 'g', -1, 64), '^')
+		return nil
+	}
+	s.prependTNetstring(strconv.AppendInt(a[:0], int64(d), 10), '#')
+	return nil
+}
+
+// newTimeDecoder returns the decoderFunc for time.Time, time.Duration and pointers to them, or nil for other types.
+// If layout is nil, the one of the DecoderOptions is used.
+func newTimeDecoder(t reflect.Type, layout *TimeLayout) decoderFunc {
+	switch t {
+	case timeType:
+		return func(d *decodeState, c byte, data []byte, _ int64, rv reflect.Value) error {
+			return decodeTime(c, data, d.timeLayout(layout), rv)
+		}
+	case durationType:
+		return func(d *decodeState, c byte, data []byte, _ int64, rv reflect.Value) error {
+			return decodeDuration(c, data, d.timeLayout(layout), rv)
+		}
+	}
+	if t.Kind() == reflect.Ptr {
+		if elem := newTimeDecoder(t.Elem(), layout); elem != nil {
+			return ptrDecoder(t, elem)
+		}
+	}
+	return nil
+}
+
+func (d *decodeState) timeLayout(layout *TimeLayout) TimeLayout {
+	if layout != nil {
+		return *layout
+	}
+	return d.opts.TimeLayout
+}
+
+// decodeTime decodes a string in RFC 3339, an integer of seconds or nanoseconds depending on the layout,
+// or a float of seconds into a time.Time. Times from numbers are in UTC.
+func decodeTime(c byte, data []byte, layout TimeLayout, rv reflect.Value) error {
+	var t time.Time
+	switch c {
+	case ',', ';':
+		if err := t.UnmarshalText(data); err != nil {
+			return err
+		}
+	case '#':
+		i, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return err
+		}
+		if layout == TimeUnixNano {
+			t = time.Unix(0, i).UTC()
+		} else {
+			t = time.Unix(i, 0).UTC()
+		}
+	case '^':
+		f, err := strconv.ParseFloat(string(data), 64)
+		if err != nil {
+			return err
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return ErrNonFinite
+		}
+		sec, frac := math.Modf(f)
+		t = time.Unix(int64(sec), int64(math.Round(frac*1e9))).UTC()
+	case '~':
+	default:
+		return ErrUnsupportedType{Type: rv.Type()}
+	}
+	rv.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// decodeDuration decodes a string of time.ParseDuration, an integer of nanoseconds or seconds depending on
+// the layout, or a float of seconds into a time.Duration.
+func decodeDuration(c byte, data []byte, layout TimeLayout, rv reflect.Value) error {
+	var d time.Duration
+	switch c {
+	case ',', ';':
+		var err error
+		if d, err = time.ParseDuration(string(data)); err != nil {
+			return err
+		}
+	case '#':
+		i, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return err
+		}
+		d = time.Duration(i)
+		if layout == TimeUnix {
+			d *= time.Second
+		}
+	case '^':
+		f, err := strconv.ParseFloat(string(data), 64)
+		if err != nil {
+			return err
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return ErrNonFinite
+		}
+		d = time.Duration(math.Round(f * float64(time.Second)))
+	case '~':
+	default:
+		return ErrUnsupportedType{Type: rv.Type()}
+	}
+	rv.SetInt(int64(d))
+	return nil
+}
@@ -0,0 +1,109 @@
+package tnetstrings
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testTime = time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+
+type testEvent struct {
+	At   time.Time     `tnetstrings:"at"`
+	Unix time.Time     `tnetstrings:"unix,unix"`
+	Nano *time.Time    `tnetstrings:"nano,unixnano"`
+	Took time.Duration `tnetstrings:"took"`
+	Wait time.Duration `tnetstrings:"wait,unix"`
+}
+
+func TestMarshal_time(t *testing.T) {
+	in := testEvent{
+		At:   testTime,
+		Unix: testTime,
+		Nano: &testTime,
+		Took: 1500 * time.Millisecond,
+		Wait: 1500 * time.Millisecond,
+	}
+	out := "124:2:at,30:2024-05-06T07:08:09.123456789Z,4:unix,10:1714979289#4:nano,19:1714979289123456789#" +
+		"4:took,10:1500000000#4:wait,3:1.5^}"
+
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != out {
+		t.Errorf("expected: %s, got: %s", out, b)
+	}
+
+	var e testEvent
+	if err := Unmarshal(b, &e); err != nil {
+		t.Fatal(err)
+	}
+	in.Unix = testTime.Truncate(time.Second)
+	if !reflect.DeepEqual(in, e) {
+		t.Errorf("expected: %v, got: %v", in, e)
+	}
+
+	testCases := []struct {
+		layout TimeLayout
+		in     interface{}
+		out    string
+	}{
+		{TimeRFC3339, testTime, "30:2024-05-06T07:08:09.123456789Z,"},
+		{TimeUnix, testTime, "10:1714979289#"},
+		{TimeUnixNano, testTime, "19:1714979289123456789#"},
+		{TimeRFC3339, 2 * time.Second, "10:2000000000#"},
+		{TimeUnix, 2 * time.Second, "1:2^"},
+		{TimeUnixNano, 2 * time.Second, "10:2000000000#"},
+		{TimeUnix, (*time.Time)(nil), "0:~"},
+	}
+	for _, tc := range testCases {
+		b, err := EncoderOptions{TimeLayout: tc.layout}.Marshal(tc.in)
+		if err != nil {
+			t.Errorf("%v %v: %v", tc.layout, tc.in, err)
+		}
+		if string(b) != tc.out {
+			t.Errorf("%v %v: expected: %s, got: %s", tc.layout, tc.in, tc.out, b)
+		}
+	}
+}
+
+func TestUnmarshal_time(t *testing.T) {
+	testCases := []struct {
+		layout   TimeLayout
+		in       string
+		expected interface{}
+	}{
+		{TimeRFC3339, "30:2024-05-06T07:08:09.123456789Z,", testTime},
+		{TimeRFC3339, "10:1714979289#", testTime.Truncate(time.Second)},
+		{TimeUnixNano, "19:1714979289123456789#", testTime},
+		{TimeRFC3339, "12:1714979289.5^", time.Unix(1714979289, 5e8).UTC()},
+		{TimeRFC3339, "0:~", time.Time{}},
+		{TimeRFC3339, "10:1500000000#", 1500 * time.Millisecond},
+		{TimeUnix, "1:2#", 2 * time.Second},
+		{TimeRFC3339, "3:1.5^", 1500 * time.Millisecond},
+		{TimeRFC3339, "4:1m3s,", 63 * time.Second},
+	}
+	for _, tc := range testCases {
+		v := reflect.New(reflect.TypeOf(tc.expected))
+		if err := (DecoderOptions{TimeLayout: tc.layout}).Unmarshal([]byte(tc.in), v.Interface()); err != nil {
+			t.Errorf("%v %s: %v", tc.layout, tc.in, err)
+		}
+		if !reflect.DeepEqual(tc.expected, v.Elem().Interface()) {
+			t.Errorf("%v %s: expected: %v, got: %v", tc.layout, tc.in, tc.expected, v.Elem().Interface())
+		}
+	}
+
+	var tm time.Time
+	err := Unmarshal([]byte("4:true!"), &tm)
+	expected := &DecodeError{
+		Offset:   2,
+		Type:     timeType,
+		Actual:   '!',
+		Expected: ",;#^~",
+		Err:      ErrUnsupportedType{Type: timeType},
+	}
+	if !reflect.DeepEqual(expected, err) {
+		t.Errorf("expected: %v, got: %v", expected, err)
+	}
+}
@@ -0,0 +1,320 @@
+package tnetstrings
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+)
+
+// Token holds a value of one of these types:
+//
+//	Delim, for the start and the end of a dictionary or a list
+//	Scalar, for a string, an integer, a float, a boolean or a null
+type Token interface{}
+
+// Delim is the start or the end of a dictionary or a list: '{' and '}' for a dictionary, '[' and ']' for a list.
+type Delim byte
+
+func (d Delim) String() string {
+	return string(d)
+}
+
+// Scalar is a token of a tnetstring other than a dictionary or a list.
+type Scalar struct {
+	Type byte
+	Data []byte
+}
+
+// Value returns the scalar decoded as it would be into an interface{}.
+func (s Scalar) Value() (interface{}, error) {
+	var v interface{}
+	var d decodeState
+	if err := decodeKind(&d, s.Type, s.Data, 0, reflect.ValueOf(&v).Elem()); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// frame is a dictionary or a list opened by Token.
+type frame struct {
+	t     byte  // the type char
+	end   int64 // the offset of the type char
+	items int   // the number of elements read so far
+}
+
+// Token returns the next token in the stream. At the end of the stream it returns nil and io.EOF.
+//
+// Since a type char comes after the payload, the Decoder has to look ahead to tell a dictionary or a list
+// from a string. If the underlying reader is an io.ReaderAt it reads just the type char. Otherwise a payload
+// larger than the buffer is read into memory.
+//
+// Decode, Skip and Token can be mixed to decode a part of the stream into a typed destination.
+func (d *Decoder) Token() (Token, error) {
+	if n := len(d.stack); n > 0 && d.offset >= d.stack[n-1].end {
+		f := d.stack[n-1]
+		d.stack = d.stack[:n-1]
+		if d.offset != f.end || (f.t == '}' && f.items%2 != 0) {
+			return nil, &SyntaxError{Offset: f.end, Err: io.ErrUnexpectedEOF}
+		}
+		var t [1]byte
+		if err := d.readFull(t[:]); err != nil {
+			return nil, err
+		}
+		if t[0] != f.t {
+			return nil, &SyntaxError{Offset: f.end, Err: ErrInvalidTypeChar(t[0])}
+		}
+		return Delim(t[0]), nil
+	}
+
+	if err := d.countItem(); err != nil {
+		return nil, err
+	}
+	start := d.offset
+	size, err := d.readSize()
+	if err == io.EOF && len(d.stack) > 0 {
+		err = &SyntaxError{Offset: d.offset, Err: io.ErrUnexpectedEOF}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := d.checkSize(d.offset, size); err != nil {
+		return nil, err
+	}
+	t, err := d.peekByte(size)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.checkKey(t, d.offset+int64(size)); err != nil {
+		return nil, err
+	}
+	if d.state.opts.Strict {
+		if err := checkCanonicalSize(start, d.offset, int(size)); err != nil {
+			return nil, err
+		}
+	}
+	switch t {
+	case '}', ']':
+		if max := d.state.opts.MaxDepth; max > 0 && len(d.stack) >= max {
+			return nil, &LimitError{Offset: d.offset, Err: ErrMaxDepth}
+		}
+		d.stack = append(d.stack, frame{t: t, end: d.offset + int64(size)})
+		if t == '}' {
+			return Delim('{'), nil
+		}
+		return Delim('['), nil
+	case ',', ';', '#', '^', '!', '~':
+		off := d.offset
+		data := make([]byte, size+1)
+		if err := d.readFull(data); err != nil {
+			return nil, err
+		}
+		if d.state.opts.Strict {
+			if err := d.state.checkStrict(start, t, data[:size], off); err != nil {
+				return nil, err
+			}
+		}
+		return Scalar{Type: t, Data: data[:size]}, nil
+	default:
+		return nil, &SyntaxError{Offset: d.offset + int64(size), Err: ErrInvalidTypeChar(t)}
+	}
+}
+
+// countItem counts the element about to be read in the innermost dictionary or list opened by Token
+// and checks the item limit. The entries of a dictionary are counted as key-value pairs.
+func (d *Decoder) countItem() error {
+	n := len(d.stack)
+	if n == 0 || d.offset >= d.stack[n-1].end {
+		return nil
+	}
+	f := &d.stack[n-1]
+	i := f.items
+	f.items++
+	if f.t == '}' {
+		if i%2 != 0 {
+			return nil
+		}
+		i /= 2
+	}
+	return d.state.checkItems(i, d.offset)
+}
+
+// checkKey returns a *SyntaxError if the element of the type char t at offset off is a dictionary key
+// opened by Token but not a string.
+func (d *Decoder) checkKey(t byte, off int64) error {
+	n := len(d.stack)
+	if n == 0 || d.stack[n-1].t != '}' || d.stack[n-1].items%2 == 0 || !isTypeChar(t) || t == ',' || t == ';' {
+		return nil
+	}
+	return &SyntaxError{Offset: off, Err: ErrNonStringKey}
+}
+
+// peekByte returns the byte at pos bytes ahead of the current offset without consuming anything.
+func (d *Decoder) peekByte(pos uint64) (byte, error) {
+	if pos < uint64(d.Size()) {
+		b, err := d.Peek(int(pos) + 1)
+		if err == io.EOF {
+			return 0, &SyntaxError{Offset: d.offset + int64(len(b)), Err: io.ErrUnexpectedEOF}
+		}
+		if err != nil {
+			return 0, err
+		}
+		return b[pos], nil
+	}
+
+	if d.at != nil {
+		var b [1]byte
+		_, err := d.at.ReadAt(b[:], d.base+d.offset+int64(pos))
+		if err == io.EOF {
+			return 0, &SyntaxError{Offset: d.offset + int64(pos), Err: io.ErrUnexpectedEOF}
+		}
+		if err != nil {
+			return 0, err
+		}
+		return b[0], nil
+	}
+
+	data := make([]byte, pos+1)
+	if n, err := io.ReadFull(d.Reader, data); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, &SyntaxError{Offset: d.offset + int64(n), Err: io.ErrUnexpectedEOF}
+		}
+		return 0, err
+	}
+	d.unread(data)
+	return data[pos], nil
+}
+
+// unread pushes data back in front of the stream.
+func (d *Decoder) unread(data []byte) {
+	if d.src == nil {
+		d.src = &pendingReader{pending: data, r: d.Reader}
+		d.Reader = bufio.NewReaderSize(d.src, d.Size())
+		return
+	}
+	buffered, _ := d.Peek(d.Buffered())
+	pending := make([]byte, 0, len(data)+len(buffered)+len(d.src.pending))
+	pending = append(pending, data...)
+	pending = append(pending, buffered...)
+	d.src.pending = append(pending, d.src.pending...)
+	d.Reader.Reset(d.src)
+}
+
+// pendingReader reads the pending bytes and then r.
+type pendingReader struct {
+	pending []byte
+	r       io.Reader
+}
+
+func (p *pendingReader) Read(b []byte) (int, error) {
+	if len(p.pending) == 0 {
+		return p.r.Read(b)
+	}
+	n := copy(b, p.pending)
+	p.pending = p.pending[n:]
+	return n, nil
+}
+
+// PeekType returns the type char and SIZE of the next tnetstring in the stream without consuming it.
+// At the end of the stream it returns io.EOF.
+func (d *Decoder) PeekType() (byte, uint64, error) {
+	t, size, _, err := d.peek()
+	return t, size, err
+}
+
+// peek returns the type char, SIZE and the length of SIZE and `:` of the next tnetstring without consuming it.
+func (d *Decoder) peek() (byte, uint64, int, error) {
+	b, err := d.Peek(limit)
+	if len(b) == 0 {
+		return 0, 0, 0, err
+	}
+	size, n, err := parseSize(b)
+	switch {
+	case err == io.ErrUnexpectedEOF:
+		return 0, 0, 0, &SyntaxError{Offset: d.offset + int64(n), Err: err}
+	case err != nil:
+		return 0, 0, 0, &SyntaxError{Offset: d.offset + int64(n) - 1, Err: err}
+	}
+	if err := d.checkSize(d.offset+int64(n), size); err != nil {
+		return 0, 0, 0, err
+	}
+	t, err := d.peekByte(uint64(n) + size)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if !isTypeChar(t) {
+		return 0, 0, 0, &SyntaxError{Offset: d.offset + int64(n) + int64(size), Err: ErrInvalidTypeChar(t)}
+	}
+	return t, size, n, nil
+}
+
+// Next returns the next tnetstring in the stream as is, including its SIZE and type char.
+// The content of a dictionary or a list isn't checked.
+func (d *Decoder) Next() ([]byte, error) {
+	if err := d.countItem(); err != nil {
+		return nil, err
+	}
+	t, size, n, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+	if err := d.checkKey(t, d.offset+int64(n)+int64(size)); err != nil {
+		return nil, err
+	}
+	start := d.offset
+	raw := make([]byte, uint64(n)+size+1)
+	if err := d.readFull(raw); err != nil {
+		return nil, err
+	}
+	if d.state.opts.Strict {
+		if err := checkCanonicalSize(start, start+int64(n), int(size)); err != nil {
+			return nil, err
+		}
+		d.state.depth = len(d.stack)
+		if err := d.state.checkStrict(start, t, raw[n:uint64(n)+size], start+int64(n)); err != nil {
+			return nil, err
+		}
+	}
+	return raw, nil
+}
+
+// Skip discards the next value in the stream without decoding it.
+// At the end of the stream it returns io.EOF.
+func (d *Decoder) Skip() error {
+	if err := d.countItem(); err != nil {
+		return err
+	}
+	start := d.offset
+	size, err := d.readSize()
+	if err != nil {
+		return err
+	}
+	if err := d.checkSize(d.offset, size); err != nil {
+		return err
+	}
+	if d.state.opts.Strict {
+		if err := checkCanonicalSize(start, d.offset, int(size)); err != nil {
+			return err
+		}
+	}
+	n, err := d.Discard(int(size))
+	d.offset += int64(n)
+	if err == nil {
+		var t byte
+		if t, err = d.ReadByte(); err == nil {
+			d.offset++
+			if !isTypeChar(t) {
+				return &SyntaxError{Offset: d.offset - 1, Err: ErrInvalidTypeChar(t)}
+			}
+			return d.checkKey(t, d.offset-1)
+		}
+	}
+	if err == io.EOF {
+		return &SyntaxError{Offset: d.offset, Err: io.ErrUnexpectedEOF}
+	}
+	return err
+}
+
+// Depth returns the number of dictionaries and lists opened by Token and not closed yet.
+func (d *Decoder) Depth() int {
+	return len(d.stack)
+}
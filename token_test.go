@@ -0,0 +1,390 @@
+package tnetstrings
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecoder_Token(t *testing.T) {
+	in := "53:3:foo,10:1:1#3:bar,]3:baz,23:3:qux,0:~4:quux,4:true!}}" + "3:1.5^"
+
+	var tokens []Token
+	var depths []int
+	d := NewDecoder(strings.NewReader(in))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, tok)
+		depths = append(depths, d.Depth())
+	}
+
+	expected := []Token{
+		Delim('{'),
+		Scalar{Type: ',', Data: []byte("foo")},
+		Delim('['),
+		Scalar{Type: '#', Data: []byte("1")},
+		Scalar{Type: ',', Data: []byte("bar")},
+		Delim(']'),
+		Scalar{Type: ',', Data: []byte("baz")},
+		Delim('{'),
+		Scalar{Type: ',', Data: []byte("qux")},
+		Scalar{Type: '~', Data: []byte{}},
+		Scalar{Type: ',', Data: []byte("quux")},
+		Scalar{Type: '!', Data: []byte("true")},
+		Delim('}'),
+		Delim('}'),
+		Scalar{Type: '^', Data: []byte("1.5")},
+	}
+	if !reflect.DeepEqual(expected, tokens) {
+		t.Errorf("expected: %v, got: %v", expected, tokens)
+	}
+	if expected := []int{1, 1, 2, 2, 2, 1, 1, 2, 2, 2, 2, 2, 1, 0, 0}; !reflect.DeepEqual(expected, depths) {
+		t.Errorf("expected: %v, got: %v", expected, depths)
+	}
+
+	v, err := Scalar{Type: '^', Data: []byte("1.5")}.Value()
+	if err != nil {
+		t.Error(err)
+	}
+	if v != 1.5 {
+		t.Errorf("expected: %v, got: %v", 1.5, v)
+	}
+}
+
+func TestDecoder_Token_dictionary(t *testing.T) {
+	testCases := []struct {
+		title string
+		in    string
+		read  func(d *Decoder) error
+		err   error
+	}{
+		{
+			title: "non string key",
+			in:    "8:1:1#1:2#}",
+			read: func(d *Decoder) error {
+				_, err := d.Token()
+				return err
+			},
+			err: &SyntaxError{Offset: 5, Err: ErrNonStringKey},
+		},
+		{
+			title: "skip non string key",
+			in:    "8:1:1#1:2#}",
+			read:  (*Decoder).Skip,
+			err:   &SyntaxError{Offset: 5, Err: ErrNonStringKey},
+		},
+		{
+			title: "next non string key",
+			in:    "8:1:1#1:2#}",
+			read: func(d *Decoder) error {
+				_, err := d.Next()
+				return err
+			},
+			err: &SyntaxError{Offset: 5, Err: ErrNonStringKey},
+		},
+		{
+			title: "missing value",
+			in:    "4:1:a,}",
+			read: func(d *Decoder) error {
+				if _, err := d.Token(); err != nil {
+					return err
+				}
+				_, err := d.Token()
+				return err
+			},
+			err: &SyntaxError{Offset: 6, Err: io.ErrUnexpectedEOF},
+		},
+	}
+
+	for _, tc := range testCases {
+		d := NewDecoder(strings.NewReader(tc.in))
+		if tok, err := d.Token(); err != nil || tok != Delim('{') {
+			t.Fatalf("[%s] expected: %v, got: %v, %v", tc.title, Delim('{'), tok, err)
+		}
+		if err := tc.read(d); !reflect.DeepEqual(tc.err, err) {
+			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
+		}
+	}
+}
+
+func TestDecoder_Token_decode(t *testing.T) {
+	type item struct {
+		Name string
+	}
+
+	in := "55:5:items,43:13:4:Name,3:foo,}13:4:Name,3:bar,}6:ignore,]}"
+
+	d := NewDecoder(strings.NewReader(in))
+	for _, expected := range []Token{Delim('{'), Scalar{Type: ',', Data: []byte("items")}, Delim('[')} {
+		tok, err := d.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, tok) {
+			t.Fatalf("expected: %v, got: %v", expected, tok)
+		}
+	}
+
+	var items []item
+	for i := 0; d.More(); i++ {
+		if i == 2 {
+			if err := d.Skip(); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		var it item
+		if err := d.Decode(&it); err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, it)
+	}
+	if expected := []item{{Name: "foo"}, {Name: "bar"}}; !reflect.DeepEqual(expected, items) {
+		t.Errorf("expected: %#v, got: %#v", expected, items)
+	}
+
+	for _, expected := range []Token{Delim(']'), Delim('}')} {
+		tok, err := d.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected != tok {
+			t.Errorf("expected: %v, got: %v", expected, tok)
+		}
+	}
+	if _, err := d.Token(); err != io.EOF {
+		t.Errorf("expected: %v, got: %v", io.EOF, err)
+	}
+}
+
+func TestDecoder_Token_large(t *testing.T) {
+	elems := make([]string, 10000)
+	for i := range elems {
+		elems[i] = "foo"
+	}
+	b, err := Marshal(elems)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		title string
+		in    io.Reader
+	}{
+		{
+			title: "reader at",
+			in:    bytes.NewReader(b),
+		},
+		{
+			title: "reader",
+			in:    struct{ io.Reader }{bytes.NewReader(b)},
+		},
+	}
+
+	for _, tc := range testCases {
+		d := NewDecoder(tc.in)
+		tok, err := d.Token()
+		if err != nil {
+			t.Fatalf("[%s] %v", tc.title, err)
+		}
+		if tok != Delim('[') {
+			t.Errorf("[%s] expected: %v, got: %v", tc.title, Delim('['), tok)
+		}
+		n := 0
+		for d.More() {
+			if err := d.Skip(); err != nil {
+				t.Fatalf("[%s] %v", tc.title, err)
+			}
+			n++
+		}
+		if n != len(elems) {
+			t.Errorf("[%s] expected: %d, got: %d", tc.title, len(elems), n)
+		}
+		if tok, err := d.Token(); err != nil || tok != Delim(']') {
+			t.Errorf("[%s] expected: %v, got: %v, %v", tc.title, Delim(']'), tok, err)
+		}
+	}
+}
+
+func TestDecoder_Token_pushBack(t *testing.T) {
+	elems := make([]string, 2000)
+	for i := range elems {
+		elems[i] = "foo"
+	}
+	var in []byte
+	for i := 0; i < 3; i++ {
+		b, err := Marshal(elems)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in = append(in, b...)
+	}
+
+	d := NewDecoder(struct{ io.Reader }{bytes.NewReader(in)})
+	r := d.Reader
+	for i := 0; i < 3; i++ {
+		if tok, err := d.Token(); err != nil || tok != Delim('[') {
+			t.Fatalf("expected: %v, got: %v, %v", Delim('['), tok, err)
+		}
+		var s string
+		if err := d.Decode(&s); err != nil || s != "foo" {
+			t.Fatalf("expected: foo, got: %s, %v", s, err)
+		}
+		for d.More() {
+			if err := d.Skip(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if tok, err := d.Token(); err != nil || tok != Delim(']') {
+			t.Fatalf("expected: %v, got: %v, %v", Delim(']'), tok, err)
+		}
+	}
+	if d.More() {
+		t.Error("expected the end of the stream")
+	}
+	if d.Reader != r {
+		t.Error("expected the same Reader")
+	}
+}
+
+func TestDecoder_Reset(t *testing.T) {
+	elems := make([]string, 2000)
+	for i := range elems {
+		elems[i] = "foo"
+	}
+	b, err := Marshal(elems)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		title string
+		in    func() io.Reader
+	}{
+		{
+			title: "reader at",
+			in:    func() io.Reader { return bytes.NewReader(b) },
+		},
+		{
+			title: "reader",
+			in:    func() io.Reader { return struct{ io.Reader }{bytes.NewReader(b)} },
+		},
+	}
+
+	for _, tc := range testCases {
+		d := NewDecoder(strings.NewReader("3:foo,3:bar,"))
+		var s string
+		if err := d.Decode(&s); err != nil {
+			t.Fatalf("[%s] %v", tc.title, err)
+		}
+
+		d.Reset(tc.in())
+		if tok, err := d.Token(); err != nil || tok != Delim('[') {
+			t.Fatalf("[%s] expected: %v, got: %v, %v", tc.title, Delim('['), tok, err)
+		}
+		n := 0
+		for d.More() {
+			if err := d.Skip(); err != nil {
+				t.Fatalf("[%s] %v", tc.title, err)
+			}
+			n++
+		}
+		if n != len(elems) {
+			t.Errorf("[%s] expected: %d, got: %d", tc.title, len(elems), n)
+		}
+		if tok, err := d.Token(); err != nil || tok != Delim(']') {
+			t.Errorf("[%s] expected: %v, got: %v, %v", tc.title, Delim(']'), tok, err)
+		}
+		if _, err := d.Token(); err != io.EOF {
+			t.Errorf("[%s] expected: %v, got: %v", tc.title, io.EOF, err)
+		}
+	}
+
+	d := NewDecoder(strings.NewReader("4:1:a,]"))
+	if _, err := d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	d.Reset(strings.NewReader("3:foo,"))
+	if d.Depth() != 0 {
+		t.Errorf("expected: 0, got: %d", d.Depth())
+	}
+	var s string
+	if err := d.Decode(&s); err != nil || s != "foo" {
+		t.Errorf("expected: foo, got: %s, %v", s, err)
+	}
+}
+
+func TestDecoder_PeekType(t *testing.T) {
+	in := "3:foo," + "10:3:foo,1:1#}" + "2:42#" + "0:]"
+
+	testCases := []struct {
+		t    byte
+		size uint64
+		next string
+		skip bool
+	}{
+		{t: ',', size: 3, next: "3:foo,"},
+		{t: '}', size: 10, skip: true},
+		{t: '#', size: 2, next: "2:42#"},
+		{t: ']', size: 0, next: "0:]"},
+	}
+
+	d := NewDecoder(strings.NewReader(in))
+	for i, tc := range testCases {
+		c, size, err := d.PeekType()
+		if err != nil {
+			t.Fatalf("[%d] %v", i, err)
+		}
+		if c != tc.t || size != tc.size {
+			t.Errorf("[%d] expected: %c %d, got: %c %d", i, tc.t, tc.size, c, size)
+		}
+		if tc.skip {
+			if err := d.Skip(); err != nil {
+				t.Errorf("[%d] %v", i, err)
+			}
+			continue
+		}
+		b, err := d.Next()
+		if err != nil {
+			t.Errorf("[%d] %v", i, err)
+		}
+		if string(b) != tc.next {
+			t.Errorf("[%d] expected: %s, got: %s", i, tc.next, b)
+		}
+	}
+	if _, _, err := d.PeekType(); err != io.EOF {
+		t.Errorf("expected: %v, got: %v", io.EOF, err)
+	}
+	if _, err := d.Next(); err != io.EOF {
+		t.Errorf("expected: %v, got: %v", io.EOF, err)
+	}
+	if err := d.Skip(); err != io.EOF {
+		t.Errorf("expected: %v, got: %v", io.EOF, err)
+	}
+
+	d = NewDecoder(strings.NewReader("3:foo?"))
+	if _, _, err := d.PeekType(); !reflect.DeepEqual(err, &SyntaxError{Offset: 5, Err: ErrInvalidTypeChar('?')}) {
+		t.Errorf("expected: %v, got: %v", ErrInvalidTypeChar('?'), err)
+	}
+	d = NewDecoder(strings.NewReader("10:foo,"))
+	if _, _, err := d.PeekType(); !reflect.DeepEqual(err, &SyntaxError{Offset: 7, Err: io.ErrUnexpectedEOF}) {
+		t.Errorf("expected: %v, got: %v", io.ErrUnexpectedEOF, err)
+	}
+
+	d = NewDecoder(strings.NewReader("3:foo?"))
+	if err := d.Skip(); !reflect.DeepEqual(err, &SyntaxError{Offset: 5, Err: ErrInvalidTypeChar('?')}) {
+		t.Errorf("expected: %v, got: %v", ErrInvalidTypeChar('?'), err)
+	}
+	d = NewDecoder(strings.NewReader("3:foo"))
+	if err := d.Skip(); !reflect.DeepEqual(err, &SyntaxError{Offset: 5, Err: io.ErrUnexpectedEOF}) {
+		t.Errorf("expected: %v, got: %v", io.ErrUnexpectedEOF, err)
+	}
+}
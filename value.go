@@ -0,0 +1,191 @@
+package tnetstrings
+
+import "strconv"
+
+// Value is a parsed tnetstring which keeps what decoding into interface{} loses:
+// the exact type char, the payload and the order of dictionary entries.
+type Value struct {
+	// Type is the type char.
+	Type byte
+
+	// Data is the payload. It's ignored on encoding a dictionary or a list.
+	Data []byte
+
+	// Entries are the key-value pairs of a dictionary in order.
+	Entries []Entry
+
+	// Items are the elements of a list.
+	Items []Value
+}
+
+// Entry is a key-value pair of a dictionary.
+type Entry struct {
+	Key   Value
+	Value Value
+}
+
+// Parse parses exactly one tnetstring. The returned Value refers to data.
+// Errors in the input are reported as a *SyntaxError.
+func Parse(data []byte) (Value, error) {
+	t, payload, off, err := splitTop(data)
+	if err != nil {
+		return Value{}, err
+	}
+	return parseValue(t, payload, off)
+}
+
+// parseValue parses the tnetstring of type char t whose payload is found at offset off.
+func parseValue(t byte, payload []byte, off int64) (Value, error) {
+	v := Value{Type: t, Data: payload}
+	s := scanner{data: payload, off: off}
+	switch t {
+	case '}':
+		for s.more() {
+			var e Entry
+			u, k, _, err := s.key()
+			if err != nil {
+				return Value{}, err
+			}
+			e.Key = Value{Type: u, Data: k}
+			u, p, pOff, err := s.next()
+			if err == nil {
+				e.Value, err = parseValue(u, p, pOff)
+			}
+			if err != nil {
+				return Value{}, withPath(err, keyElem(k))
+			}
+			v.Entries = append(v.Entries, e)
+		}
+	case ']':
+		for i := 0; s.more(); i++ {
+			u, p, pOff, err := s.next()
+			var item Value
+			if err == nil {
+				item, err = parseValue(u, p, pOff)
+			}
+			if err != nil {
+				return Value{}, withPath(err, indexElem(i))
+			}
+			v.Items = append(v.Items, item)
+		}
+	}
+	return v, nil
+}
+
+// MarshalTNetstring returns the tnetstring of v. Dictionaries and lists are encoded from Entries and Items.
+func (v Value) MarshalTNetstring() ([]byte, error) {
+	s := newEncodeState()
+	defer s.release()
+	if err := v.prependTo(s); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), s.bytes()...), nil
+}
+
+func (v *Value) prependTo(s *encodeState) error {
+	switch v.Type {
+	case '}':
+		mark := s.open('}')
+		for i := len(v.Entries) - 1; i >= 0; i-- {
+			if err := v.Entries[i].Value.prependTo(s); err != nil {
+				return err
+			}
+			if err := v.Entries[i].Key.prependTo(s); err != nil {
+				return err
+			}
+		}
+		s.close(mark)
+	case ']':
+		mark := s.open(']')
+		for i := len(v.Items) - 1; i >= 0; i-- {
+			if err := v.Items[i].prependTo(s); err != nil {
+				return err
+			}
+		}
+		s.close(mark)
+	default:
+		if !isTypeChar(v.Type) {
+			return ErrInvalidTypeChar(v.Type)
+		}
+		s.prependTNetstring(v.Data, v.Type)
+	}
+	return nil
+}
+
+// UnmarshalTNetstring sets *v to the parsed copy of data.
+func (v *Value) UnmarshalTNetstring(data []byte) error {
+	p, err := Parse(append([]byte(nil), data...))
+	if err != nil {
+		return err
+	}
+	*v = p
+	return nil
+}
+
+// Int returns the integer.
+func (v Value) Int() (int64, error) {
+	if v.Type != '#' {
+		return 0, ErrTypeMismatch(v.Type)
+	}
+	return strconv.ParseInt(string(v.Data), 10, 64)
+}
+
+// Float returns the float or the integer as a float.
+func (v Value) Float() (float64, error) {
+	if v.Type != '^' && v.Type != '#' {
+		return 0, ErrTypeMismatch(v.Type)
+	}
+	return strconv.ParseFloat(string(v.Data), 64)
+}
+
+// Bool returns the boolean.
+func (v Value) Bool() (bool, error) {
+	if v.Type != '!' {
+		return false, ErrTypeMismatch(v.Type)
+	}
+	return strconv.ParseBool(string(v.Data))
+}
+
+// Str returns the string.
+func (v Value) Str() (string, error) {
+	if v.Type != ',' && v.Type != ';' {
+		return "", ErrTypeMismatch(v.Type)
+	}
+	return string(v.Data), nil
+}
+
+// Bytes returns the string as a byte slice which refers to the payload.
+func (v Value) Bytes() ([]byte, error) {
+	if v.Type != ',' && v.Type != ';' {
+		return nil, ErrTypeMismatch(v.Type)
+	}
+	return v.Data, nil
+}
+
+// IsNull reports whether v is a null.
+func (v Value) IsNull() bool {
+	return v.Type == '~'
+}
+
+// Len returns the number of entries of a dictionary or items of a list, otherwise 0.
+func (v Value) Len() int {
+	return len(v.Entries) + len(v.Items)
+}
+
+// Get returns the value of the first entry with key in a dictionary.
+func (v Value) Get(key string) (Value, bool) {
+	for _, e := range v.Entries {
+		if string(e.Key.Data) == key {
+			return e.Value, true
+		}
+	}
+	return Value{}, false
+}
+
+// Index returns the i-th item of a list.
+func (v Value) Index(i int) (Value, bool) {
+	if i < 0 || i >= len(v.Items) {
+		return Value{}, false
+	}
+	return v.Items[i], true
+}
@@ -0,0 +1,153 @@
+package tnetstrings
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	in := "55:1:z,5:bytes,1:a;4:text;5:items;20:1:1#3:1.5^4:true!0:~]}"
+
+	v, err := Parse([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Type != '}' || v.Len() != 3 {
+		t.Errorf("expected: } 3, got: %c %d", v.Type, v.Len())
+	}
+
+	var keys []string
+	for _, e := range v.Entries {
+		keys = append(keys, string(e.Key.Data)+string(e.Key.Type))
+	}
+	if expected := []string{"z,", "a;", "items;"}; !reflect.DeepEqual(expected, keys) {
+		t.Errorf("expected: %v, got: %v", expected, keys)
+	}
+
+	z, _ := v.Get("z")
+	if b, err := z.Bytes(); err != nil || string(b) != "bytes" || z.Type != ',' {
+		t.Errorf("expected: bytes, got: %s %v", b, err)
+	}
+	a, _ := v.Get("a")
+	if s, err := a.Str(); err != nil || s != "text" || a.Type != ';' {
+		t.Errorf("expected: text, got: %s %v", s, err)
+	}
+	if _, ok := v.Get("missing"); ok {
+		t.Error("expected no value for a missing key")
+	}
+
+	items, _ := v.Get("items")
+	first, _ := items.Index(0)
+	if i, err := first.Int(); err != nil || i != 1 {
+		t.Errorf("expected: 1, got: %d %v", i, err)
+	}
+	if f, err := first.Float(); err != nil || f != 1 {
+		t.Errorf("expected: 1, got: %f %v", f, err)
+	}
+	second, _ := items.Index(1)
+	if f, err := second.Float(); err != nil || f != 1.5 {
+		t.Errorf("expected: 1.5, got: %f %v", f, err)
+	}
+	if _, err := second.Int(); err != ErrTypeMismatch('^') {
+		t.Errorf("expected: %v, got: %v", ErrTypeMismatch('^'), err)
+	}
+	if i, err := (Value{Type: '#', Data: []byte("010")}).Int(); err != nil || i != 10 {
+		t.Errorf("expected: 10, got: %d %v", i, err)
+	}
+	if _, err := (Value{Type: '#', Data: []byte("0x10")}).Int(); err == nil {
+		t.Error("expected an error for a hexadecimal integer")
+	}
+	third, _ := items.Index(2)
+	if b, err := third.Bool(); err != nil || !b {
+		t.Errorf("expected: true, got: %t %v", b, err)
+	}
+	fourth, _ := items.Index(3)
+	if !fourth.IsNull() {
+		t.Errorf("expected null, got: %c", fourth.Type)
+	}
+	if _, ok := items.Index(4); ok {
+		t.Error("expected no value out of range")
+	}
+
+	b, err := Marshal(v)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != in {
+		t.Errorf("expected: %s, got: %s", in, b)
+	}
+
+	v.Entries = v.Entries[1:]
+	v.Entries[1].Value.Items = append(v.Entries[1].Value.Items, Value{Type: ',', Data: []byte("new")})
+	b, err = Marshal(v)
+	if err != nil {
+		t.Error(err)
+	}
+	if expected := "49:1:a;4:text;5:items;26:1:1#3:1.5^4:true!0:~3:new,]}"; string(b) != expected {
+		t.Errorf("expected: %s, got: %s", expected, b)
+	}
+
+	if b, err := (Value{}).MarshalTNetstring(); err != ErrInvalidTypeChar(0) || b != nil {
+		t.Errorf("expected: %v, got: %q, %v", ErrInvalidTypeChar(0), b, err)
+	}
+	list := Value{Type: ']', Items: []Value{{}}}
+	if _, err := list.MarshalTNetstring(); err != ErrInvalidTypeChar(0) {
+		t.Errorf("expected: %v, got: %v", ErrInvalidTypeChar(0), err)
+	}
+}
+
+func TestParse_error(t *testing.T) {
+	testCases := []struct {
+		title string
+		in    string
+		err   error
+	}{
+		{
+			title: "trailing data",
+			in:    "0:~0:~",
+			err:   &SyntaxError{Offset: 3, Err: ErrTrailingData},
+		},
+		{
+			title: "non string key",
+			in:    "8:1:1#1:1#}",
+			err:   &SyntaxError{Offset: 5, Err: ErrNonStringKey},
+		},
+		{
+			title: "missing value",
+			in:    "4:1:a,}",
+			err:   &SyntaxError{Offset: 6, Path: "a", Err: io.ErrUnexpectedEOF},
+		},
+		{
+			title: "invalid type char",
+			in:    "4:1:a?]",
+			err:   &SyntaxError{Offset: 5, Path: "[0]", Err: ErrInvalidTypeChar('?')},
+		},
+	}
+
+	for _, tc := range testCases {
+		if _, err := Parse([]byte(tc.in)); !reflect.DeepEqual(tc.err, err) {
+			t.Errorf("[%s] expected: %v, got: %v", tc.title, tc.err, err)
+		}
+	}
+}
+
+func TestValue_unmarshal(t *testing.T) {
+	type envelope struct {
+		Kind string
+		Body Value
+	}
+
+	in := envelope{Kind: "kind", Body: Value{Type: ']', Items: []Value{{Type: '#', Data: []byte("1")}}}}
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out envelope
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if i, _ := out.Body.Index(0); out.Kind != "kind" || string(i.Data) != "1" {
+		t.Errorf("expected: %#v, got: %#v", in, out)
+	}
+}